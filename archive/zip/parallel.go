@@ -0,0 +1,239 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// ParallelWriter writes entries with zip.Writer.CreateRaw, added in Go 1.17.
+
+//go:build go1.17
+// +build go1.17
+
+package zip
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var errWriterClosed = errors.New("zip: write to closed ParallelWriter")
+
+// A ParallelWriter builds a zip archive whose entries are compressed
+// concurrently by a pool of workers. Entries are written in the order they
+// are added, each with its CRC-32 and sizes in its local header, and ZIP64
+// records are used where sizes or the number of entries require them.
+//
+// Each compressed entry is held in memory until it is written, and at most
+// twice as many entries as there are workers are in flight, so memory use
+// is bounded by the compressed size of the largest entries.
+//
+// The methods of a ParallelWriter must not be called concurrently.
+type ParallelWriter struct {
+	zw *zip.Writer
+	c  *compressor
+
+	jobs    chan *entry // entries waiting for a worker
+	order   chan *entry // entries in archive order, waiting to be written
+	workers sync.WaitGroup
+	written chan struct{} // closed once all entries are written
+	closed  bool
+
+	mu  sync.Mutex
+	err error // first error met
+}
+
+// entry is an archive entry on its way through a ParallelWriter.
+type entry struct {
+	hdr   zip.FileHeader
+	open  func() (io.ReadCloser, error)
+	data  bytes.Buffer // compressed content
+	err   error
+	ready chan struct{} // closed once data is complete
+}
+
+// NewParallelWriter returns a ParallelWriter writing an archive to w,
+// compressing Deflate entries at the given level on the given number of
+// workers. If workers is not positive, runtime.GOMAXPROCS(0) is used.
+func NewParallelWriter(w io.Writer, level, workers int) (*ParallelWriter, error) {
+	c, err := newCompressor(level)
+	if err != nil {
+		return nil, err
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	p := &ParallelWriter{
+		zw:      zip.NewWriter(w),
+		c:       c,
+		jobs:    make(chan *entry, 2*workers),
+		order:   make(chan *entry, 2*workers),
+		written: make(chan struct{}),
+	}
+	p.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	go p.write()
+	return p, nil
+}
+
+// Create adds an entry described by hdr, whose content is read from the
+// ReadCloser returned by open once a worker picks the entry up. The
+// Method of hdr must be zip.Store or zip.Deflate; its CRC32 and sizes are
+// filled in by the ParallelWriter, which keeps its own copy of hdr.
+// Directories, whose name ends in a slash, must have a nil open.
+//
+// Create blocks while too many entries are in flight. It returns the first
+// error met so far, whether by a worker or while writing the archive.
+func (p *ParallelWriter) Create(hdr *zip.FileHeader, open func() (io.ReadCloser, error)) error {
+	if p.closed {
+		return errWriterClosed
+	}
+	if err := p.failed(); err != nil {
+		return err
+	}
+	e := &entry{hdr: *hdr, open: open, ready: make(chan struct{})}
+	if strings.HasSuffix(e.hdr.Name, "/") {
+		if open != nil {
+			return fmt.Errorf("zip: directory %q with content", e.hdr.Name)
+		}
+		e.hdr.Method = zip.Store
+	} else if e.hdr.Method != zip.Store && e.hdr.Method != zip.Deflate {
+		return fmt.Errorf("zip: unsupported compression method %d for %q", e.hdr.Method, e.hdr.Name)
+	}
+	p.order <- e
+	p.jobs <- e
+	return nil
+}
+
+// AddFS adds the files and directories of fsys to the archive, walking it
+// in lexical order. Files are compressed with Deflate.
+func (p *ParallelWriter) AddFS(fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && !info.Mode().IsRegular() {
+			return fmt.Errorf("zip: %q is not a regular file", name)
+		}
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if d.IsDir() {
+			hdr.Name += "/"
+			return p.Create(hdr, nil)
+		}
+		hdr.Method = zip.Deflate
+		return p.Create(hdr, func() (io.ReadCloser, error) { return fsys.Open(name) })
+	})
+}
+
+// Close waits for all entries to be written, then writes the central
+// directory. It does not close the underlying writer.
+func (p *ParallelWriter) Close() error {
+	if p.closed {
+		return errWriterClosed
+	}
+	p.closed = true
+	close(p.jobs)
+	close(p.order)
+	p.workers.Wait()
+	<-p.written
+	if err := p.failed(); err != nil {
+		return err
+	}
+	return p.zw.Close()
+}
+
+func (p *ParallelWriter) failed() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *ParallelWriter) fail(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+}
+
+// work compresses entries until there are no more.
+func (p *ParallelWriter) work() {
+	defer p.workers.Done()
+	for e := range p.jobs {
+		if p.failed() == nil {
+			e.err = p.compress(e)
+		}
+		close(e.ready)
+	}
+}
+
+// compress reads the content of e and fills in e.data and e.hdr.
+func (p *ParallelWriter) compress(e *entry) error {
+	e.hdr.Flags &^= 0x8 // sizes go in the local header, not a data descriptor
+	if e.open == nil {
+		return nil
+	}
+	r, err := e.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	sum := crc32.NewIEEE()
+	var n int64
+	if e.hdr.Method == zip.Store {
+		n, err = io.Copy(io.MultiWriter(&e.data, sum), r)
+	} else {
+		fw := p.c.get(&e.data)
+		fw.SetChecksum(sum)
+		n, err = io.Copy(fw, r)
+		if err == nil {
+			err = fw.Close()
+		}
+		p.c.put(fw)
+	}
+	if err != nil {
+		return fmt.Errorf("zip: %s: %w", e.hdr.Name, err)
+	}
+	e.hdr.CRC32 = sum.Sum32()
+	e.hdr.UncompressedSize64 = uint64(n)
+	e.hdr.CompressedSize64 = uint64(e.data.Len())
+	return nil
+}
+
+// write writes entries to the archive in order as they become ready.
+func (p *ParallelWriter) write() {
+	defer close(p.written)
+	for e := range p.order {
+		<-e.ready
+		if e.err != nil {
+			p.fail(e.err)
+		}
+		if p.failed() != nil {
+			// Keep draining so that Create and Close do not block.
+			continue
+		}
+		w, err := p.zw.CreateRaw(&e.hdr)
+		if err == nil {
+			_, err = e.data.WriteTo(w)
+		}
+		if err != nil {
+			p.fail(err)
+		}
+		e.data = bytes.Buffer{}
+	}
+}
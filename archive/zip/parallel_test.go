@@ -0,0 +1,229 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build go1.17
+// +build go1.17
+
+package zip
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestParallelWriter(t *testing.T) {
+	if _, err := NewParallelWriter(io.Discard, 42, 0); err == nil {
+		t.Fatal("NewParallelWriter accepted level 42")
+	}
+	var buf bytes.Buffer
+	p, err := NewParallelWriter(&buf, 1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 300
+	var names []string
+	for i := 0; i < n; i++ {
+		hdr := &zip.FileHeader{Name: fmt.Sprintf("dir%d/file%d", i%7, i), Method: zip.Deflate}
+		if i%5 == 0 {
+			hdr.Method = zip.Store
+		}
+		hdr.Modified = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		data := testContent(i)
+		err := p.Create(hdr, func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+		if hdr.CRC32 != 0 || hdr.UncompressedSize64 != 0 {
+			t.Fatal("Create modified the caller's header")
+		}
+	}
+	if err := p.Create(&zip.FileHeader{Name: "empty/"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Create(&zip.FileHeader{Name: "bad", Method: 99}, nil); err == nil {
+		t.Fatal("Create accepted method 99")
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Create(&zip.FileHeader{Name: "late"}, nil); err == nil {
+		t.Fatal("Create succeeded after Close")
+	}
+
+	zr, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotNames, contents := readAll(t, zr)
+	if len(gotNames) != n+1 || gotNames[n] != "empty/" {
+		t.Fatalf("got %d entries", len(gotNames))
+	}
+	for i := 0; i < n; i++ {
+		f := zr.File[i]
+		if gotNames[i] != names[i] || !bytes.Equal(contents[i], testContent(i)) {
+			t.Fatalf("entry %d: got %s, want %s", i, gotNames[i], names[i])
+		}
+		if f.Flags&0x8 != 0 || f.UncompressedSize64 != uint64(len(contents[i])) {
+			t.Fatalf("%s: flags %#x, size %d", f.Name, f.Flags, f.UncompressedSize64)
+		}
+	}
+}
+
+func TestParallelWriterError(t *testing.T) {
+	p, err := NewParallelWriter(io.Discard, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errOpen := errors.New("cannot open")
+	for i := 0; i < 100; i++ {
+		open := func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("content")), nil
+		}
+		if i == 10 {
+			open = func() (io.ReadCloser, error) { return nil, errOpen }
+		}
+		if err := p.Create(&zip.FileHeader{Name: fmt.Sprint(i)}, open); err != nil {
+			if err != errOpen {
+				t.Fatalf("Create: %v", err)
+			}
+			break
+		}
+	}
+	if err := p.Close(); err != errOpen {
+		t.Fatalf("Close: got %v, want %v", err, errOpen)
+	}
+}
+
+func TestParallelWriterAddFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":    {Data: []byte(strings.Repeat("<p>hello</p>", 1000))},
+		"js/app.js":     {Data: []byte(strings.Repeat("var x;", 1000))},
+		"js/vendor.js":  {Data: testContent(1)},
+		"css/site.css":  {Data: testContent(0)},
+		"img/empty.png": {Data: nil},
+	}
+	var buf bytes.Buffer
+	p, err := NewParallelWriter(&buf, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddFS(fsys); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, contents := readAll(t, zr)
+	want := "css/ css/site.css img/ img/empty.png index.html js/ js/app.js js/vendor.js"
+	if got := strings.Join(names, " "); got != want {
+		t.Fatalf("entries: got %q, want %q", got, want)
+	}
+	for i, name := range names {
+		if f, ok := fsys[name]; ok && !bytes.Equal(contents[i], f.Data) {
+			t.Fatalf("%s: content mismatch", name)
+		}
+	}
+}
+
+// TestParallelWriterZip64 checks an archive with more entries than the
+// classic end of central directory record can count.
+func TestParallelWriterZip64(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	var buf bytes.Buffer
+	p, err := NewParallelWriter(&buf, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 1<<16 + 10
+	open := func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader("x")), nil }
+	for i := 0; i < n; i++ {
+		if err := p.Create(&zip.FileHeader{Name: fmt.Sprint(i), Method: zip.Store}, open); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != n || zr.File[n-1].Name != fmt.Sprint(n-1) {
+		t.Fatalf("got %d entries, want %d", len(zr.File), n)
+	}
+}
+
+// zeroReader reads zeros.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// TestParallelWriterZip64Size checks an entry larger than 4 GB, whose sizes
+// only fit in ZIP64 extra fields, next to a small one after it.
+func TestParallelWriterZip64Size(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	const size = 1<<32 + 1<<20
+	var buf bytes.Buffer
+	p, err := NewParallelWriter(&buf, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	big := func() (io.ReadCloser, error) { return io.NopCloser(io.LimitReader(zeroReader{}, size)), nil }
+	small := func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader("after")), nil }
+	if err := p.Create(&zip.FileHeader{Name: "zeros", Method: zip.Deflate}, big); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Create(&zip.FileHeader{Name: "after", Method: zip.Deflate}, small); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 2 || zr.File[0].UncompressedSize64 != size {
+		t.Fatalf("got %d entries, first of %d bytes", len(zr.File), zr.File[0].UncompressedSize64)
+	}
+	r, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The reader checks the CRC-32 at the end.
+	n, err := io.Copy(io.Discard, r)
+	if err != nil || n != size {
+		t.Fatalf("read %d bytes, %v", n, err)
+	}
+	r.Close()
+	if r, err = zr.File[1].Open(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := io.ReadAll(r); err != nil || string(data) != "after" {
+		t.Fatalf("after: got %q, %v", data, err)
+	}
+}
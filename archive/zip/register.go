@@ -0,0 +1,178 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package zip plugs the Intel-optimized fastgo DEFLATE implementation into
+// the standard archive/zip package, and builds archives compressing their
+// entries in parallel.
+//
+// The standard library registers its own Deflate compressor globally and
+// does not allow replacing it, so fastgo is registered per zip.Writer and
+// zip.Reader instead.
+//
+// ParallelWriter needs Go 1.17 or later, for zip.Writer.CreateRaw; the rest
+// of the package builds with Go 1.16 like the rest of the module.
+package zip
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/intel/fastgo/compress/flate"
+)
+
+var errClosed = errors.New("zip: use of closed entry stream")
+
+// RegisterCompressor makes zw compress Deflate entries with fastgo at the
+// given level. Writers are pooled and reused across entries.
+func RegisterCompressor(zw *zip.Writer, level int) error {
+	c, err := newCompressor(level)
+	if err != nil {
+		return err
+	}
+	zw.RegisterCompressor(zip.Deflate, c.compress)
+	return nil
+}
+
+// RegisterDecompressor makes zr decompress Deflate entries with fastgo.
+// Readers are pooled and reused across entries.
+func RegisterDecompressor(zr *zip.Reader) {
+	zr.RegisterDecompressor(zip.Deflate, decompress)
+}
+
+// NewWriter returns a zip.Writer writing to w that compresses Deflate
+// entries with fastgo at the given level.
+func NewWriter(w io.Writer, level int) (*zip.Writer, error) {
+	zw := zip.NewWriter(w)
+	if err := RegisterCompressor(zw, level); err != nil {
+		return nil, err
+	}
+	return zw, nil
+}
+
+// NewReader is like zip.NewReader, but the returned reader decompresses
+// Deflate entries with fastgo.
+func NewReader(r io.ReaderAt, size int64) (*zip.Reader, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	RegisterDecompressor(zr)
+	return zr, nil
+}
+
+// OpenReader is like zip.OpenReader, but the returned reader decompresses
+// Deflate entries with fastgo.
+func OpenReader(name string) (*zip.ReadCloser, error) {
+	rc, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+	RegisterDecompressor(&rc.Reader)
+	return rc, nil
+}
+
+// compressor pools the flate writers of one compression level.
+type compressor struct {
+	level int
+	pool  sync.Pool
+}
+
+func newCompressor(level int) (*compressor, error) {
+	if _, err := flate.NewWriter(io.Discard, level); err != nil {
+		return nil, fmt.Errorf("zip: invalid compression level: %d", level)
+	}
+	return &compressor{level: level}, nil
+}
+
+// get returns a flate writer writing to w.
+func (c *compressor) get(w io.Writer) *flate.Writer {
+	if fw, ok := c.pool.Get().(*flate.Writer); ok {
+		fw.Reset(w)
+		return fw
+	}
+	// The level was checked by newCompressor.
+	fw, _ := flate.NewWriter(w, c.level)
+	return fw
+}
+
+func (c *compressor) put(fw *flate.Writer) {
+	fw.SetChecksum(nil)
+	fw.Reset(io.Discard) // drop the reference to the archive
+	c.pool.Put(fw)
+}
+
+// compress implements zip.Compressor.
+func (c *compressor) compress(w io.Writer) (io.WriteCloser, error) {
+	return &pooledWriter{fw: c.get(w), c: c}, nil
+}
+
+// pooledWriter returns its flate writer to the pool once closed.
+type pooledWriter struct {
+	mu sync.Mutex
+	fw *flate.Writer
+	c  *compressor
+}
+
+func (w *pooledWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fw == nil {
+		return 0, errClosed
+	}
+	return w.fw.Write(p)
+}
+
+func (w *pooledWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var err error
+	if w.fw != nil {
+		err = w.fw.Close()
+		w.c.put(w.fw)
+		w.fw = nil
+	}
+	return err
+}
+
+var readerPool sync.Pool
+
+// decompress implements zip.Decompressor.
+func decompress(r io.Reader) io.ReadCloser {
+	fr, ok := readerPool.Get().(io.ReadCloser)
+	if ok {
+		fr.(flate.Resetter).Reset(r, nil)
+	} else {
+		fr = flate.NewReader(r)
+	}
+	return &pooledReader{fr: fr}
+}
+
+// pooledReader returns its flate reader to the pool once closed.
+type pooledReader struct {
+	mu sync.Mutex
+	fr io.ReadCloser
+}
+
+func (r *pooledReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fr == nil {
+		return 0, errClosed
+	}
+	return r.fr.Read(p)
+}
+
+func (r *pooledReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var err error
+	if r.fr != nil {
+		err = r.fr.Close()
+		readerPool.Put(r.fr)
+		r.fr = nil
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package zip
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// testContent returns the content of entry i: text, random bytes or
+// nothing, of varying sizes.
+func testContent(i int) []byte {
+	rnd := rand.New(rand.NewSource(int64(i)))
+	switch i % 4 {
+	case 0:
+		return []byte(strings.Repeat(fmt.Sprintf("line %d of a text file\n", i), rnd.Intn(5000)))
+	case 1:
+		b := make([]byte, rnd.Intn(100000))
+		rnd.Read(b)
+		return b
+	case 2:
+		return nil
+	}
+	return bytes.Repeat([]byte{byte(i)}, rnd.Intn(300000))
+}
+
+// readAll reads every entry of the archive read by zr, checking their
+// CRC-32 on the way, and returns their names and contents.
+func readAll(t *testing.T, zr *zip.Reader) (names []string, contents [][]byte) {
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		if err := rc.Close(); err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		names = append(names, f.Name)
+		contents = append(contents, data)
+	}
+	return names, contents
+}
+
+func TestRegister(t *testing.T) {
+	if _, err := NewWriter(io.Discard, 42); err == nil {
+		t.Fatal("NewWriter accepted level 42")
+	}
+	var buf bytes.Buffer
+	zw, err := NewWriter(&buf, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		w, err := zw.Create(fmt.Sprintf("file%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(testContent(i))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read back with both the standard and the fastgo decompressor.
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, want := readAll(t, zr)
+	zr, err = NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, got := readAll(t, zr)
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) || !bytes.Equal(got[i], testContent(i)) {
+			t.Fatalf("file%d: content mismatch", i)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package autodetect provides a Reader that recognizes whether its input is
+// gzip, zlib or raw DEFLATE compressed, or not compressed at all, and
+// decompresses it with the matching fastgo reader.
+//
+// This helps with payloads whose labels cannot be trusted, such as HTTP
+// bodies marked "deflate" that are sometimes zlib and sometimes raw
+// DEFLATE, or gzip files with the wrong extension.
+package autodetect
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/intel/fastgo/compress/flate"
+	"github.com/intel/fastgo/compress/gzip"
+	"github.com/intel/fastgo/compress/zlib"
+)
+
+// SniffLen is the number of bytes NewReader looks at to detect the format.
+const SniffLen = 4096
+
+// Format is a compression format recognized by Detect.
+type Format int
+
+const (
+	Uncompressed Format = iota // none of the formats below
+	Gzip                       // RFC 1952
+	Zlib                       // RFC 1950
+	Deflate                    // raw RFC 1951 stream
+)
+
+var formatNames = [...]string{
+	Uncompressed: "uncompressed",
+	Gzip:         "gzip",
+	Zlib:         "zlib",
+	Deflate:      "deflate",
+}
+
+func (f Format) String() string {
+	if f < 0 || int(f) >= len(formatNames) {
+		return "unknown"
+	}
+	return formatNames[f]
+}
+
+// Detect reports the format of data that starts with prefix. more tells
+// whether prefix may be followed by more data; if it is false, prefix is
+// taken to be the whole input.
+//
+// The gzip magic number and the zlib header checksum are only trusted if
+// the data that follows them is valid. The compressed blocks in prefix are
+// walked, without producing the decompressed data, and must be well formed
+// and must not refer back before the start of the data. They must either
+// run past the end of prefix or end where the data ends, or where its zlib
+// trailer ends, or, for gzip, before a trailer and possibly more members.
+// Raw DEFLATE followed by other data within prefix is thus not recognized,
+// as accepting it would let some short texts pass for DEFLATE. Text and
+// binary data fail these checks in practice, so uncompressed input is
+// reported as Uncompressed. Zlib streams that need a preset dictionary are
+// not recognized.
+func Detect(prefix []byte, more bool) Format {
+	switch {
+	case isGzip(prefix, more):
+		return Gzip
+	case isZlib(prefix, more):
+		return Zlib
+	case isDeflate(prefix, more, 0):
+		return Deflate
+	}
+	return Uncompressed
+}
+
+// Flags of the gzip header (RFC 1952, section 2.3.1).
+const (
+	gzipHeaderCRC = 1 << 1
+	gzipExtra     = 1 << 2
+	gzipName      = 1 << 3
+	gzipComment   = 1 << 4
+)
+
+func isGzip(prefix []byte, more bool) bool {
+	// Check the magic number, the method and that no reserved flag is set.
+	if len(prefix) < 4 || prefix[0] != 0x1f || prefix[1] != 0x8b || prefix[2] != 8 || prefix[3]&0xe0 != 0 {
+		return false
+	}
+	flg, n := prefix[3], 10 // past MTIME, XFL and OS
+	if flg&gzipExtra != 0 {
+		if len(prefix) < n+2 {
+			return more
+		}
+		n += 2 + int(binary.LittleEndian.Uint16(prefix[n:]))
+	}
+	for _, f := range []byte{gzipName, gzipComment} {
+		if flg&f != 0 && n < len(prefix) {
+			i := bytes.IndexByte(prefix[n:], 0)
+			if i < 0 {
+				return more
+			}
+			n += i + 1
+		}
+	}
+	if flg&gzipHeaderCRC != 0 {
+		n += 2
+	}
+	if n >= len(prefix) {
+		return more
+	}
+	tail, ok := deflateTail(prefix[n:])
+	if tail < 0 {
+		return more
+	}
+	// The 8-byte trailer may be cut off by the end of prefix.
+	return ok && (tail >= 8 || more)
+}
+
+func isZlib(prefix []byte, more bool) bool {
+	if len(prefix) < 2 {
+		return false
+	}
+	cmf, flg := prefix[0], prefix[1]
+	if cmf&0x0f != 8 || cmf>>4 > 7 || (uint(cmf)<<8|uint(flg))%31 != 0 || flg&0x20 != 0 {
+		return false
+	}
+	return isDeflate(prefix[2:], more, 4)
+}
+
+// isDeflate reports whether prefix starts with well-formed DEFLATE blocks
+// that run past its end or are followed by exactly trailer bytes.
+func isDeflate(prefix []byte, more bool, trailer int) bool {
+	tail, ok := deflateTail(prefix)
+	if tail < 0 {
+		return more
+	}
+	return ok && tail == trailer
+}
+
+// splicers holds the Splicers that deflateTail checks streams with, so that
+// detection does not allocate a decoder each time.
+var splicers = sync.Pool{
+	New: func() interface{} { return flate.NewSplicer(io.Discard) },
+}
+
+// deflateTail walks the DEFLATE blocks at the start of prefix and returns
+// the number of bytes that follow them, or -1 if they run past the end of
+// prefix, and whether they are well formed so far.
+func deflateTail(prefix []byte) (int, bool) {
+	if len(prefix) == 0 {
+		return 0, false
+	}
+	// A Splicer writing nowhere checks the block structure without
+	// producing the decompressed data.
+	s := splicers.Get().(*flate.Splicer)
+	defer splicers.Put(s)
+	s.Reset(io.Discard)
+	r := bytes.NewReader(prefix)
+	_, err := s.Add(r)
+	if err == io.ErrUnexpectedEOF {
+		return -1, true
+	}
+	return r.Len(), err == nil
+}
+
+// Reader decompresses data in the format detected by NewReader.
+type Reader struct {
+	format Format
+	r      io.Reader
+}
+
+// NewReader returns a Reader that decompresses the data read from r, after
+// detecting its format from the first SniffLen bytes with Detect.
+// Uncompressed data is passed through unchanged. The Reader may read more
+// data than necessary from r. Detection reuses pooled decoders, so only the
+// decompressor for the detected format is allocated.
+//
+// An error is returned if reading r fails, or if the decompressor for the
+// detected format rejects its header.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReaderSize(r, SniffLen)
+	prefix, err := br.Peek(SniffLen)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	z := &Reader{format: Detect(prefix, err == nil)}
+	err = nil
+	switch z.format {
+	case Gzip:
+		z.r, err = gzip.NewReader(br)
+	case Zlib:
+		z.r, err = zlib.NewReader(br)
+	case Deflate:
+		z.r = flate.NewReader(br)
+	default:
+		z.r = br
+	}
+	if err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+// Format returns the detected format.
+func (z *Reader) Format() Format { return z.format }
+
+// Read reads decompressed data.
+func (z *Reader) Read(p []byte) (int, error) { return z.r.Read(p) }
+
+// Close closes the decompressor, if any. It does not close the underlying
+// io.Reader.
+func (z *Reader) Close() error {
+	if c, ok := z.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package autodetect
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/intel/fastgo/compress/flate"
+	"github.com/intel/fastgo/compress/gzip"
+	"github.com/intel/fastgo/compress/zlib"
+)
+
+func compress(t *testing.T, format Format, data []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch format {
+	case Gzip:
+		w = gzip.NewWriter(&buf)
+	case Zlib:
+		w = zlib.NewWriter(&buf)
+	case Deflate:
+		w, _ = flate.NewWriter(&buf, flate.BestSpeed)
+	default:
+		return data
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewReader(t *testing.T) {
+	payloads := [][]byte{
+		[]byte(""),
+		[]byte("x"),
+		[]byte(`{"status": "ok"}`),
+		[]byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 1000)),
+	}
+	rnd := make([]byte, 3*SniffLen)
+	rand.New(rand.NewSource(1)).Read(rnd)
+	payloads = append(payloads, rnd)
+
+	for _, format := range []Format{Uncompressed, Gzip, Zlib, Deflate} {
+		for _, data := range payloads {
+			in := compress(t, format, data)
+			z, err := NewReader(bytes.NewReader(in))
+			if err != nil {
+				t.Fatalf("%v, %d bytes: NewReader: %v", format, len(data), err)
+			}
+			if z.Format() != format {
+				t.Fatalf("%v, %d bytes: Format = %v", format, len(data), z.Format())
+			}
+			got, err := io.ReadAll(z)
+			if err != nil || !bytes.Equal(got, data) {
+				t.Fatalf("%v, %d bytes: read %d bytes, %v", format, len(data), len(got), err)
+			}
+			if err := z.Close(); err != nil {
+				t.Fatalf("%v: Close: %v", format, err)
+			}
+		}
+	}
+}
+
+// TestUncompressed checks inputs that resemble the headers of compressed
+// formats without being compressed.
+func TestUncompressed(t *testing.T) {
+	for _, s := range []string{
+		"x = 1\n",           // a valid zlib CMF/FLG pair with FDICT set
+		"x^2 + y^2\n",       // a valid zlib CMF/FLG pair
+		"{\"a\": [1, 2]}\n", // starts with a final fixed Huffman block
+		"\x1f\x8b\x08 not a gzip header",
+		"<html><body>hello</body></html>\n",
+		strings.Repeat("2024-01-01T00:00:00Z GET /index.html 200\n", 200),
+	} {
+		z, err := NewReader(strings.NewReader(s))
+		if err != nil {
+			t.Fatalf("%q: NewReader: %v", s, err)
+		}
+		if z.Format() != Uncompressed {
+			t.Fatalf("%q: Format = %v, want %v", s, z.Format(), Uncompressed)
+		}
+		if got, err := io.ReadAll(z); err != nil || string(got) != s {
+			t.Fatalf("%q: read %q, %v", s, got, err)
+		}
+	}
+}
+
+// TestDetectAllocs checks that Detect reuses its decoders.
+func TestDetectAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool does not keep items under the race detector")
+	}
+	data := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 1000))
+	for _, format := range []Format{Gzip, Zlib, Deflate} {
+		in := compress(t, format, data)
+		if len(in) > SniffLen {
+			in = in[:SniffLen]
+		}
+		Detect(in, true)
+		allocs := testing.AllocsPerRun(100, func() {
+			if Detect(in, true) != format {
+				t.Fatalf("Detect = %v, want %v", Detect(in, true), format)
+			}
+		})
+		if allocs > 1 {
+			t.Errorf("%v: Detect made %v allocations, want at most 1", format, allocs)
+		}
+	}
+}
+
+// TestDetectTrailingData checks that gzip members may be followed by more
+// data while raw DEFLATE may not.
+func TestDetectTrailingData(t *testing.T) {
+	for _, tc := range []struct {
+		format Format
+		want   Format
+	}{{Gzip, Gzip}, {Zlib, Uncompressed}, {Deflate, Uncompressed}} {
+		in := compress(t, tc.format, []byte("a short payload"))
+		in = append(in, in...)
+		if got := Detect(in, false); got != tc.want {
+			t.Errorf("%v twice: Detect = %v, want %v", tc.format, got, tc.want)
+		}
+	}
+}
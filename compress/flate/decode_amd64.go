@@ -29,18 +29,18 @@ func decodeHuffman(state *inflate, output []byte, written int) (w int, err error
 		var errno int
 		// Use assembly-optimized implementation with safety margins
 		written, errno = decodeHuffmanAsmArchV3(state, output[:len(output)-outBufferSlop], written)
-		if errno != 0 && errno != errorNoEndInput {
-			switch errno {
-			case errorNoInvalidBlock:
-				err = errInvalidBlock
-			case errorNoInvalidSymbol:
-				err = errInvalidSymbol
-			case errorNoInvalidLookback:
-				err = errInvalidLookBack
-			case errorNoOutOverflow:
-				err = errOutputOverflow
-			}
-			return written, err
+		switch {
+		case errno == errorNoInvalidBlock:
+			return written, errInvalidBlock
+		case errno == errorNoInvalidSymbol:
+			return written, errInvalidSymbol
+		case errno < 0:
+			// The assembly returns -3-d for an invalid look-back distance
+			// d, with written at the start of the rejected match.
+			return written, errInvalidLookBack
+		}
+		if errno == errorNoOutOverflow {
+			return written, errOutputOverflow
 		}
 		if errno == 0 {
 			state.phase = phaseNewBlock
@@ -1,4 +1,10 @@
-// Code generated by command: go run main.go. DO NOT EDIT.
+// This file was first generated with avo. The generator is not part of
+// this repository, so the file is now maintained by hand.
+//
+// decodeHuffmanAsmArchV3 returns errno 0 at the end of a block, 1 when it
+// runs out of input, 2 when it runs out of output, -2 on an invalid symbol
+// and -3-d on an invalid look-back distance d, with written rewound to the
+// start of the rejected match.
 
 #include "textflag.h"
 
@@ -279,7 +285,9 @@ end_of_input:
         JMP  end
 
 invalid_look_back_distance:
-        MOVQ $-1, AX
+        // Drop the match, which R10 was moved past.
+        SUBQ R15, R10
+        MOVQ $-3, AX
         SUBQ DX, AX
         JMP  end
         MOVQ $-3, AX
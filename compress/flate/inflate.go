@@ -35,6 +35,12 @@ type inflate struct {
 	dynHdr         dynamicHeaderReader // Dynamic header processing context
 
 	roffset int64 // Read offset for position tracking
+
+	// Where the header of the last block started, for Splicer: the
+	// number of block headers started, and len(input) and bitsLen then.
+	headers  int
+	hdrInput int
+	hdrBits  int32
 }
 
 type dynamicHeaderReader struct {
@@ -61,6 +67,7 @@ func (s *inflate) reset() {
 	s.copyOverflowDistance = 0
 	s.headerBuffered = 0
 	s.roffset = 0
+	s.headers = 0
 }
 
 const (
@@ -259,6 +266,10 @@ func (state *inflate) readHeader() (err error) {
 	bitsLen := state.bitsLen
 	input := state.input
 	phase := state.phase
+	if phase == phaseNewBlock {
+		state.headers++
+		state.hdrInput, state.hdrBits = len(input), bitsLen
+	}
 
 	var tempLen int
 	if phase == phaseDecodingHeader {
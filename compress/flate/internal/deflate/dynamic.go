@@ -5,10 +5,12 @@ package deflate
 
 import (
 	_ "embed"
+	"hash"
 	"io"
 	"math/bits"
 
 	"github.com/intel/fastgo/compress/flate/internal/huffman"
+	"github.com/intel/fastgo/internal/sumcopy"
 )
 
 var _ LevelCompressor = &dynCompressor{}
@@ -27,6 +29,11 @@ type dynCompressor struct {
 	distGen    *huffman.LenLimitedCode
 	hist       *histogram
 	lz77       lz77compressor
+	sum        hash.Hash32
+
+	// Match finders of level 1 and of the other levels, kept across
+	// setLevel calls.
+	contexts [2]lz77compressor
 }
 
 const (
@@ -46,8 +53,7 @@ func NewDynCompressor(w io.Writer, level int, windowSize int) *dynCompressor {
 	c.litGen = huffman.NewLenLimitedCode()
 	c.distGen = huffman.NewLenLimitedCode()
 
-	c.lz77 = buildLZ77(level, windowSize)
-	c.hist = c.lz77.histogram()
+	c.useLevel(level)
 	return c
 }
 
@@ -63,6 +69,28 @@ func buildLZ77(level, windowSize int) lz77compressor {
 }
 
 func (c *dynCompressor) Accumulate(data []byte) (n int, trigger bool) {
+	c.slide()
+	n = sumcopy.Copy(c.buffer[c.end:2*c.windowSize+maxMatchLength], data, c.sum)
+	c.end += n
+	if c.end < 2*c.windowSize+maxMatchLength {
+		return
+	}
+	return n, true
+}
+
+func (c *dynCompressor) accumulateFrom(r io.Reader) (n int, trigger bool, err error) {
+	c.slide()
+	n, err = r.Read(c.buffer[c.end : 2*c.windowSize+maxMatchLength])
+	if c.sum != nil {
+		c.sum.Write(c.buffer[c.end : c.end+n])
+	}
+	c.end += n
+	return n, c.end == 2*c.windowSize+maxMatchLength, err
+}
+
+// slide drops the data that is out of the window once the compressed data
+// reaches the second half of the buffer.
+func (c *dynCompressor) slide() {
 	// input: [history][resolved data][unresolved data]
 	if c.idx >= 2*c.windowSize {
 		offset := (c.idx - c.windowSize)
@@ -70,12 +98,62 @@ func (c *dynCompressor) Accumulate(data []byte) (n int, trigger bool) {
 		c.idx -= offset
 		c.end -= offset
 	}
-	n = copy(c.buffer[c.end:2*c.windowSize+maxMatchLength], data)
-	c.end += n
-	if c.end < 2*c.windowSize+maxMatchLength {
-		return
+}
+
+// window returns the input before the current position, up to
+// maxWindowSize bytes. All of it is still in the buffer.
+func (c *dynCompressor) window() []byte {
+	start := c.idx - maxWindowSize
+	if start < 0 {
+		start = 0
 	}
-	return n, true
+	return c.buffer[start:c.idx]
+}
+
+// useLevel makes the compressor use the match finder of the given level,
+// building it on first use. The match finder must be reset before use.
+func (c *dynCompressor) useLevel(level int) {
+	i := 1
+	if level == 1 {
+		i = 0
+	}
+	if c.contexts[i] == nil {
+		c.contexts[i] = buildLZ77(level, c.windowSize)
+	}
+	c.lz77 = c.contexts[i]
+	c.hist = c.lz77.histogram()
+}
+
+// setLevel makes the compressor use the match finder of the given level
+// from now on, keeping the data in the window. It must follow a Flush.
+func (c *dynCompressor) setLevel(level int) {
+	c.useLevel(level)
+	window := c.window()
+	c.Reset(c.w)
+	c.prime(window)
+}
+
+// prime loads the end of window as the data preceding the next input, so
+// that the next blocks can refer to it. It must follow Reset. The match
+// finder runs over the window to fill its hash table, and its tokens are
+// dropped.
+func (c *dynCompressor) prime(window []byte) {
+	if len(window) > c.windowSize {
+		window = window[len(window)-c.windowSize:]
+	}
+	c.end = copy(c.buffer, window)
+	for c.idx < c.end {
+		var nIdx int
+		nIdx, c.tokens = c.lz77.generate(true, c.buffer[:c.end], c.processed, c.idx, c.tokens, maxTokenSize)
+		c.processed += nIdx - c.idx
+		c.idx = nIdx
+		c.tokens = c.tokens[:0]
+	}
+	c.hist.reset()
+}
+
+func (c *dynCompressor) setChecksum(sum hash.Hash32) {
+	c.sum = sum
 }
 
 func (w *dynCompressor) Compress() (err error) {
@@ -165,6 +243,18 @@ func (w *dynCompressor) Flush() (err error) {
 	return err
 }
 
+func (w *dynCompressor) FullFlush() (err error) {
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+	w.processed = 0
+	w.idx = 0
+	w.end = 0
+	w.lz77.reset()
+	return nil
+}
+
 func (c *dynCompressor) Close() error {
 	err := c.compressBlock(true, true)
 	if err != nil {
@@ -179,6 +269,7 @@ func (w *dynCompressor) Reset(under io.Writer) {
 
 	w.idx = 0
 	w.end = 0
+	w.tokens = w.tokens[:0]
 
 	w.buf.reset()
 	w.lz77.reset()
@@ -4,9 +4,11 @@
 package deflate
 
 import (
+	"hash"
 	"io"
 
 	"github.com/intel/fastgo/compress/flate/internal/huffman"
+	"github.com/intel/fastgo/internal/sumcopy"
 )
 
 type huffmanOnly struct {
@@ -14,10 +16,12 @@ type huffmanOnly struct {
 	hist   histogram
 	buffer []byte
 	offset int
+	prev   int // Size of the last block with data
 	max    int
 	hdr    *dynamicHeader
 	litGen *huffman.LenLimitedCode
 	buf    BitBuf
+	sum    hash.Hash32
 }
 
 func NewHuffmanOnly(w io.Writer) *huffmanOnly {
@@ -35,10 +39,11 @@ func (h *huffmanOnly) Reset(w io.Writer) {
 	h.w = w
 	h.buf.reset()
 	h.offset = 0
+	h.prev = 0
 }
 
 func (h *huffmanOnly) Accumulate(data []byte) (n int, trigger bool) {
-	n = copy(h.buffer[h.offset:h.max], data)
+	n = sumcopy.Copy(h.buffer[h.offset:h.max], data, h.sum)
 	h.offset += n
 	if h.offset == h.max {
 		return n, true
@@ -46,8 +51,33 @@ func (h *huffmanOnly) Accumulate(data []byte) (n int, trigger bool) {
 	return n, false
 }
 
+func (h *huffmanOnly) accumulateFrom(r io.Reader) (n int, trigger bool, err error) {
+	n, err = r.Read(h.buffer[h.offset:h.max])
+	if h.sum != nil {
+		h.sum.Write(h.buffer[h.offset : h.offset+n])
+	}
+	h.offset += n
+	return n, h.offset == h.max, err
+}
+
+// window returns the data of the last block, as the buffer holds no more.
+func (h *huffmanOnly) window() []byte {
+	data := h.buffer[:h.offset]
+	if h.offset == 0 {
+		data = h.buffer[:h.prev]
+	}
+	if len(data) > maxWindowSize {
+		data = data[len(data)-maxWindowSize:]
+	}
+	return data
+}
+
+func (h *huffmanOnly) setChecksum(sum hash.Hash32) {
+	h.sum = sum
+}
+
 func (h *huffmanOnly) Compress() error {
-	return h.encodeBlock(false, false)
+	return h.encodeBlock(false)
 }
 
 func bytesFreq(hist *histogram, input []byte) {
@@ -61,7 +91,7 @@ func bytesFreq(hist *histogram, input []byte) {
 
 var optimizedEncodeBytes func(hist *histogram, data []byte, buf *BitBuf) (num int)
 
-func (h *huffmanOnly) encodeBlock(final bool, flush bool) error {
+func (h *huffmanOnly) encodeBlock(final bool) error {
 	if final && h.offset == 0 {
 		h.buf.writeFinalEmptyBlock()
 		_, err := h.w.Write(h.buf.output[:h.buf.idx])
@@ -81,7 +111,7 @@ func (h *huffmanOnly) encodeBlock(final bool, flush bool) error {
 	for num < h.offset {
 		h.buf.Sync()
 		num += optimizedEncodeBytes(&h.hist, h.buffer[num:h.offset], &h.buf)
-		if num == h.offset && flush {
+		if num == h.offset && final {
 			h.buf.flushLastByte()
 		}
 		_, err := h.w.Write(h.buf.output[:h.buf.idx])
@@ -90,12 +120,15 @@ func (h *huffmanOnly) encodeBlock(final bool, flush bool) error {
 		}
 		h.buf.idx = 0
 	}
+	if h.offset > 0 {
+		h.prev = h.offset
+	}
 	h.offset = 0
 	return nil
 }
 
 func (h *huffmanOnly) Flush() (err error) {
-	err = h.encodeBlock(false, true)
+	err = h.encodeBlock(false)
 	if err != nil {
 		return err
 	}
@@ -106,8 +139,13 @@ func (h *huffmanOnly) Flush() (err error) {
 	return err
 }
 
+// FullFlush is the same as Flush, as Huffman-only blocks keep no history.
+func (h *huffmanOnly) FullFlush() error {
+	return h.Flush()
+}
+
 func (h *huffmanOnly) Close() (err error) {
-	err = h.encodeBlock(true, true)
+	err = h.encodeBlock(true)
 	if err != nil {
 		return err
 	}
@@ -5,6 +5,7 @@ package deflate
 
 import (
 	"compress/flate"
+	"hash"
 	"io"
 )
 
@@ -21,7 +22,18 @@ type LevelCompressor interface {
 	Accumulate(data []byte) (n int, trigger bool)
 	Compress() error
 	Flush() error
+	// FullFlush is like Flush, but also discards the compression history
+	// so that the output after it does not refer to earlier data.
+	FullFlush() error
 	Close() error
+	// setChecksum makes Accumulate feed the data it copies to sum.
+	setChecksum(sum hash.Hash32)
+	// accumulateFrom is like Accumulate, but reads the data from r with a
+	// single call to its Read method.
+	accumulateFrom(r io.Reader) (n int, trigger bool, err error)
+	// window returns the most recent input, up to maxWindowSize bytes,
+	// that the compressor still holds after a Flush.
+	window() []byte
 }
 
 type lz77compressor interface {
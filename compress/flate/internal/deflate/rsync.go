@@ -0,0 +1,48 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package deflate
+
+// Rsyncable output follows the approach of `gzip --rsyncable`: a rolling sum
+// over the last rsyncWindow input bytes selects content-defined sync points.
+// At each sync point the compressor flushes to a byte boundary and drops its
+// history, so the compressed bytes that follow only depend on the input that
+// follows. A local change in the input therefore only changes the output up
+// to the next sync point after it.
+const (
+	rsyncWindow = 4096
+	rsyncMask   = rsyncWindow - 1
+)
+
+// rsyncState tracks the rolling sum used to find sync points.
+type rsyncState struct {
+	window [rsyncWindow]byte
+	count  int    // bytes seen since the last sync point
+	sum    uint32 // sum of the last rsyncWindow bytes
+}
+
+func (s *rsyncState) reset() {
+	s.count = 0
+	s.sum = 0
+}
+
+// scan consumes p up to and including the next sync point. It returns the
+// number of bytes consumed and whether they end on a sync point.
+// The window restarts after every sync point, so two sync points are
+// always at least rsyncWindow bytes apart.
+func (s *rsyncState) scan(p []byte) (n int, sync bool) {
+	for i, b := range p {
+		idx := s.count & rsyncMask
+		if s.count >= rsyncWindow {
+			s.sum -= uint32(s.window[idx])
+		}
+		s.window[idx] = b
+		s.sum += uint32(b)
+		s.count++
+		if s.count >= rsyncWindow && s.sum&rsyncMask == 0 {
+			s.reset()
+			return i + 1, true
+		}
+	}
+	return len(p), false
+}
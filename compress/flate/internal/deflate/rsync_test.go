@@ -0,0 +1,134 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package deflate
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"testing"
+)
+
+func compressRsyncable(t testing.TB, level int, data []byte) []byte {
+	buf := bytes.NewBuffer(nil)
+	w, err := NewWriter(buf, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rsyncable(true); err != nil {
+		t.Fatal(err)
+	}
+	// Write in odd-sized pieces so that sync points fall inside writes.
+	for len(data) > 0 {
+		n := 1000
+		if n > len(data) {
+			n = len(data)
+		}
+		if _, err := w.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func commonSuffix(a, b []byte) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
+
+func TestRsyncable(t *testing.T) {
+	data := opticks(t)
+	changed := append([]byte(nil), data...)
+	changed[1000] ^= 0x20
+
+	for _, lvl := range testLevels {
+		a := compressRsyncable(t, lvl, data)
+		b := compressRsyncable(t, lvl, changed)
+		for _, c := range []struct {
+			compressed, want []byte
+		}{{a, data}, {b, changed}} {
+			got, err := io.ReadAll(flate.NewReader(bytes.NewReader(c.compressed)))
+			if err != nil {
+				t.Fatalf("level %d: %v", lvl, err)
+			}
+			if !bytes.Equal(got, c.want) {
+				t.Fatalf("level %d: round trip mismatch at %d", lvl, diff(got, c.want))
+			}
+		}
+		// Only the output up to the first sync point after the change
+		// may differ.
+		if n := commonSuffix(a, b); len(a)-n > len(a)/10 {
+			t.Errorf("level %d: output differs in %d of %d bytes", lvl, len(a)-n, len(a))
+		}
+	}
+}
+
+func TestRsyncableUnsupportedLevel(t *testing.T) {
+	w, err := NewWriter(io.Discard, BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rsyncable(true); err == nil {
+		t.Fatal("expected error for level 9")
+	}
+	if err := w.Rsyncable(false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writers with a 4 KB window use the optimized compressors at level 9.
+	data := opticks(t)
+	buf := bytes.NewBuffer(nil)
+	if w, err = NewWriterwWith4KWindow(buf, BestCompression); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rsyncable(true); err != nil {
+		t.Fatalf("4 KB window: %v", err)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(flate.NewReader(buf))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("4 KB window: round trip failed: %v", err)
+	}
+}
+
+// TestRsyncableRatio measures the growth of the output in rsyncable mode
+// and the spacing of the sync points that Rsyncable documents.
+func TestRsyncableRatio(t *testing.T) {
+	// Loose bounds on the growth documented by Rsyncable, in percent.
+	maxGrowth := map[int]float64{HuffmanOnly: 1, BestSpeed: 10, DefaultCompression: 14}
+	data := opticks(t)
+	var s rsyncState
+	syncs := 0
+	for p := data; len(p) > 0; {
+		n, sync := s.scan(p)
+		if sync {
+			syncs++
+		}
+		p = p[n:]
+	}
+	t.Logf("%d sync points in %d bytes, %d bytes apart on average", syncs, len(data), len(data)/(syncs+1))
+	for _, lvl := range testLevels {
+		buf := bytes.NewBuffer(nil)
+		w, _ := NewWriter(buf, lvl)
+		w.Write(data)
+		w.Close()
+		plain := buf.Len()
+		rsync := len(compressRsyncable(t, lvl, data))
+		growth := 100 * float64(rsync-plain) / float64(plain)
+		t.Logf("level %d: %d -> %d bytes (%+.2f%%)", lvl, plain, rsync, growth)
+		if growth > maxGrowth[lvl] {
+			t.Errorf("level %d: output grew by %.2f%%, want at most %g%%", lvl, growth, maxGrowth[lvl])
+		}
+	}
+}
@@ -8,16 +8,41 @@ package deflate
 
 import (
 	"compress/flate"
+	"errors"
+	"fmt"
+	"hash"
 	"io"
 )
 
+// maxWindowSize is the largest distance a DEFLATE stream can refer back.
+const maxWindowSize = 32 * 1024
+
+// errRsyncableLevel is returned by Rsyncable for compression levels that are
+// served by the standard library, which cannot drop its history mid-stream.
+var errRsyncableLevel = errors.New("flate: rsyncable mode is not available at this compression level")
+
 // Writer implements Intel-optimized DEFLATE compression.
 // It chooses between optimized implementations and standard library
 // based on compression level and CPU capabilities.
 type Writer struct {
-	err error           // Last error encountered
-	lc  LevelCompressor // Intel-optimized compressor for supported levels
-	w   *flate.Writer   // Standard library writer for unsupported levels
+	err        error           // Last error encountered
+	lc         LevelCompressor // Intel-optimized compressor for supported levels
+	w          *flate.Writer   // Standard library writer for unsupported levels
+	rs         *rsyncState     // Rolling sum state, nil unless rsyncable mode is on
+	sum        hash.Hash32     // Checksum of the uncompressed data, may be nil
+	under      io.Writer       // Destination of the compressed data
+	level      int             // Compression level, DefaultCompression resolved
+	windowSize int             // Window size of the optimized compressors
+	dict       []byte          // Preset dictionary of the standard library writer
+	windowed   bool            // w was given earlier data by SetLevel
+	tracking   bool            // SetLevel was called, so recent follows w
+	started    bool            // Write, Flush or Close was called since Reset
+	recent     []byte          // Last input to w, once tracking
+
+	// Optimized compressors that SetLevel switched away from, reused when
+	// it switches back.
+	huff *huffmanOnly
+	dyn  *dynCompressor
 }
 
 // NewWriterwWith4KWindow creates a new compressor with a 4KB sliding window.
@@ -25,10 +50,10 @@ type Writer struct {
 // For compression levels 1, 2, and HuffmanOnly, it uses Intel optimizations.
 // Other levels fall back to standard library with custom window size.
 func NewWriterwWith4KWindow(under io.Writer, level int) (w *Writer, err error) {
-	w = &Writer{}
 	if level == DefaultCompression {
 		level = 2 // Default to level 2 for best speed/compression balance
 	}
+	w = &Writer{under: under, level: level, windowSize: 4 * 1024}
 	switch level {
 	case NoCompression:
 		// No compression - use standard library
@@ -57,7 +82,7 @@ func NewWriterDict(under io.Writer, level int, dict []byte) (w *Writer, err erro
 	if dict == nil {
 		return NewWriter(under, level)
 	}
-	w = &Writer{}
+	w = &Writer{under: under, level: level, windowSize: 32 * 1024, dict: dict}
 	w.w, err = flate.NewWriterDict(under, level, dict)
 	if err != nil {
 		return nil, err
@@ -71,10 +96,10 @@ func NewWriterDict(under io.Writer, level int, dict []byte) (w *Writer, err erro
 // - Level 1, 2: Intel-optimized with LZ77 + Huffman
 // - Other levels: Falls back to standard library
 func NewWriter(under io.Writer, level int) (w *Writer, err error) {
-	w = &Writer{}
 	if level == DefaultCompression {
 		level = 2 // Default to level 2 for balanced performance
 	}
+	w = &Writer{under: under, level: level, windowSize: 32 * 1024}
 	switch level {
 	case HuffmanOnly:
 		// Use Intel-optimized Huffman-only compression
@@ -99,11 +124,26 @@ func (w *Writer) Write(data []byte) (n int, err error) {
 	if w.err != nil {
 		return n, w.err
 	}
+	w.started = true
 	if w.w != nil {
 		// Use standard library writer
-		return w.w.Write(data)
+		n, err = w.w.Write(data)
+		if w.sum != nil {
+			w.sum.Write(data[:n])
+		}
+		if w.tracking {
+			w.remember(data[:n])
+		}
+		return n, err
 	}
-	// Use Intel-optimized compressor
+	if w.rs != nil {
+		return w.writeRsyncable(data)
+	}
+	return w.write(data)
+}
+
+// write feeds data to the Intel-optimized compressor.
+func (w *Writer) write(data []byte) (n int, err error) {
 	n = len(data)
 	var num int
 	for num < n {
@@ -122,21 +162,277 @@ func (w *Writer) Write(data []byte) (n int, err error) {
 	return num, nil
 }
 
+// ReadFrom implements io.ReaderFrom. It reads r until EOF straight into
+// the compressor's window, saving the copy from the caller's buffer that
+// Write makes, and returns the number of bytes read.
+func (w *Writer) ReadFrom(r io.Reader) (n int64, err error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.started = true
+	if w.w != nil || w.rs != nil {
+		// The standard library and rsyncable mode take their input from
+		// Write.
+		return io.Copy(writerOnly{w}, r)
+	}
+	for {
+		num, ok, rerr := w.lc.accumulateFrom(r)
+		n += int64(num)
+		if ok {
+			if err = w.lc.Compress(); err != nil {
+				w.err = err
+				return n, err
+			}
+		}
+		if rerr == io.EOF {
+			return n, nil
+		}
+		if rerr != nil {
+			return n, rerr
+		}
+	}
+}
+
+// writerOnly hides the ReadFrom method of a Writer from io.Copy.
+type writerOnly struct {
+	io.Writer
+}
+
+// writeRsyncable splits data at content-defined sync points and performs a
+// full flush at each of them.
+func (w *Writer) writeRsyncable(data []byte) (n int, err error) {
+	for len(data) > 0 {
+		size, sync := w.rs.scan(data)
+		num, err := w.write(data[:size])
+		n += num
+		if err != nil {
+			return n, err
+		}
+		if sync {
+			if err = w.lc.FullFlush(); err != nil {
+				w.err = err
+				return n, err
+			}
+		}
+		data = data[size:]
+	}
+	return n, nil
+}
+
+// Rsyncable enables or disables rsyncable output, similar to
+// `gzip --rsyncable`. In this mode a rolling sum over the last 4 KB of input
+// selects sync points. A sync point comes at least 4 KB after the previous
+// one, and from there with a chance of about 1 in 4096 at each byte, so they
+// are some 8 KB apart on average, a little more on text. At each one the
+// compressor flushes to a byte boundary and discards its history, so that a
+// local change in the input only alters the compressed output until the next
+// sync point, and rsync or content-defined chunking can match the rest.
+//
+// The cost is a lower compression ratio, as every sync point starts a new
+// block with its own Huffman tables and an empty history. TestRsyncableRatio
+// measures it on Newton's Opticks from the Go distribution, where sync
+// points are 10 KB apart, and logs it with -v: the output grows by about 7%
+// at BestSpeed and 10% at level 2, and by under 0.1% for HuffmanOnly.
+// Scanning the rolling sum and clearing the match table at each sync point
+// also costs some throughput.
+//
+// Rsyncable is available at the levels served by the optimized compressors:
+// HuffmanOnly, BestSpeed, level 2 and DefaultCompression, and every level but
+// NoCompression for writers made by NewWriterwWith4KWindow. It returns an
+// error at other levels. It may be called at any point in the stream.
+func (w *Writer) Rsyncable(ok bool) error {
+	if w.w != nil {
+		if ok {
+			return errRsyncableLevel
+		}
+		return nil
+	}
+	if !ok {
+		w.rs = nil
+		return nil
+	}
+	if w.rs == nil {
+		w.rs = &rsyncState{}
+	}
+	return nil
+}
+
+// SetChecksum makes the Writer feed all data passed to Write to sum.
+// The optimized compressors compute it as they copy the data into their
+// window, 4 KB at a time, so that the checksum reads each piece back from
+// the L1 cache instead of making a second pass over memory; this is how the
+// gzip and zlib writers maintain their CRC-32 and Adler-32.
+// A nil sum turns this off. The Writer never resets sum, not even in Reset.
+func (w *Writer) SetChecksum(sum hash.Hash32) {
+	w.sum = sum
+	if w.lc != nil {
+		w.lc.setChecksum(sum)
+	}
+}
+
+// remember keeps the last maxWindowSize bytes of input given to the
+// standard library writer, which does not give access to its window. The
+// Writer only does this once SetLevel was called, so that writers that
+// never change their level do not pay for the copy.
+func (w *Writer) remember(data []byte) {
+	if len(data) >= maxWindowSize {
+		w.recent = append(w.recent[:0], data[len(data)-maxWindowSize:]...)
+		return
+	}
+	if len(w.recent)+len(data) > 2*maxWindowSize {
+		n := copy(w.recent, w.recent[len(w.recent)-(maxWindowSize-len(data)):])
+		w.recent = w.recent[:n]
+	}
+	w.recent = append(w.recent, data...)
+}
+
+// window returns the most recent input, up to maxWindowSize bytes, after
+// a Flush. It returns nil for the standard library writer once the stream
+// has started, until SetLevel was called.
+func (w *Writer) window() []byte {
+	if w.w == nil {
+		return w.lc.window()
+	}
+	if !w.tracking {
+		if !w.started {
+			return w.dict
+		}
+		return nil
+	}
+	if len(w.recent) > maxWindowSize {
+		return w.recent[len(w.recent)-maxWindowSize:]
+	}
+	return w.recent
+}
+
+// SetLevel changes the compression level of the data written from now on,
+// like zlib's deflateParams, without starting a new stream. It flushes the
+// data written so far as Flush does, unless nothing was written, flushed or
+// closed since the Writer was made or Reset, and then switches between the
+// Huffman-only compressor, the optimized levels 1 and 2 and the standard
+// library for the other levels. The data before the switch stays in the window, so the
+// following blocks can still refer to it, with one exception: the standard
+// library writer does not expose its window, so the Writer only keeps a
+// copy of its input once SetLevel was first called. A switch away from a
+// standard library level that the Writer was created with therefore starts
+// with no shared history, as after a sync flush.
+//
+// The optimized compressors are kept when SetLevel switches away from them
+// and reused when it switches back, while a standard library writer, which
+// only takes earlier data as the dictionary it is created with, is created
+// anew on each switch to one of its levels.
+//
+// The level stays in effect after Reset. In rsyncable mode, SetLevel
+// returns an error for the levels that Rsyncable does not support. It must
+// not be called after Close, until Reset.
+func (w *Writer) SetLevel(level int) error {
+	if w.err != nil {
+		return w.err
+	}
+	if level == DefaultCompression {
+		level = 2
+	}
+	if level < HuffmanOnly || level > BestCompression {
+		return fmt.Errorf("flate: invalid compression level %d: want value in range [-2, 9]", level)
+	}
+	if level == w.level {
+		return nil
+	}
+	optimized := level == HuffmanOnly || level == 1 || level == 2
+	if w.windowSize < maxWindowSize {
+		// Writers with a 4 KB window only leave NoCompression to the
+		// standard library.
+		optimized = level != NoCompression
+	}
+	if w.rs != nil && !optimized {
+		return errRsyncableLevel
+	}
+	if w.started {
+		if err := w.Flush(); err != nil {
+			w.err = err
+			return err
+		}
+	}
+	window := w.window()
+	w.tracking = true
+	switch lc := w.lc.(type) {
+	case *huffmanOnly:
+		w.huff = lc
+	case *dynCompressor:
+		w.dyn = lc
+	}
+	switch {
+	case !optimized:
+		fw, err := flate.NewWriterDict(w.under, level, window)
+		if err != nil {
+			return err
+		}
+		if w.w == nil {
+			w.recent = append(w.recent[:0], window...)
+		}
+		w.w, w.lc, w.windowed = fw, nil, true
+	case level == HuffmanOnly:
+		if w.huff == nil {
+			w.huff = NewHuffmanOnly(w.under)
+		} else {
+			w.huff.Reset(w.under)
+		}
+		w.w, w.lc = nil, w.huff
+	case w.lc == w.dyn && w.dyn != nil:
+		w.dyn.setLevel(level)
+	default:
+		if w.dyn == nil {
+			w.dyn = NewDynCompressor(w.under, level, w.windowSize)
+		} else {
+			w.dyn.useLevel(level)
+			w.dyn.Reset(w.under)
+		}
+		w.dyn.prime(window)
+		w.w, w.lc = nil, w.dyn
+	}
+	if w.lc != nil {
+		w.lc.setChecksum(w.sum)
+	}
+	w.level = level
+	return nil
+}
+
 // Reset resets the writer to use a new underlying writer.
 // This allows reusing the same Writer instance for multiple compression tasks.
 func (w *Writer) Reset(under io.Writer) {
 	w.err = nil
+	w.under = under
+	w.started = false
 	if w.w != nil {
-		w.w.Reset(under)
+		if w.windowed {
+			// Reset would give the new stream the window of the old one.
+			w.w, _ = flate.NewWriterDict(under, w.level, w.dict)
+			w.windowed = false
+		} else {
+			w.w.Reset(under)
+		}
+		if w.tracking {
+			w.recent = w.recent[:0]
+			w.remember(w.dict)
+		}
 		return
 	}
+	if w.rs != nil {
+		w.rs.reset()
+	}
 	w.lc.Reset(under)
+	if dc, ok := w.lc.(*dynCompressor); ok && len(w.dict) > 0 {
+		// The Writer was made by NewWriterDict and left the standard
+		// library through SetLevel.
+		dc.prime(w.dict)
+	}
 }
 
 func (w *Writer) Flush() (err error) {
 	if w.err != nil {
 		return w.err
 	}
+	w.started = true
 	if w.w != nil {
 		return w.w.Flush()
 	}
@@ -147,6 +443,7 @@ func (w *Writer) Close() (err error) {
 	if w.err != nil {
 		return w.err
 	}
+	w.started = true
 	if w.w != nil {
 		return w.w.Close()
 	}
@@ -8,6 +8,7 @@ import (
 	"compress/flate"
 	_ "embed"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
@@ -15,6 +16,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
 	"text/tabwriter"
 )
 
@@ -59,6 +61,243 @@ func TestWrite(t *testing.T) {
 	}
 }
 
+// TestReadFrom checks that ReadFrom, fed by short reads, produces the same
+// stream as Write, with the same checksum.
+func TestReadFrom(t *testing.T) {
+	data := opticks(t)
+	for _, lvl := range []int{HuffmanOnly, BestSpeed, 2, 6} {
+		var want, got bytes.Buffer
+		w, _ := NewWriter(&want, lvl)
+		wantSum := crc32.NewIEEE()
+		w.SetChecksum(wantSum)
+		w.Write(data)
+		w.Close()
+
+		w, _ = NewWriter(&got, lvl)
+		sum := crc32.NewIEEE()
+		w.SetChecksum(sum)
+		n, err := w.ReadFrom(iotest.HalfReader(bytes.NewReader(data)))
+		if err != nil || n != int64(len(data)) {
+			t.Fatalf("level %d: ReadFrom = %d, %v", lvl, n, err)
+		}
+		w.Close()
+		if !bytes.Equal(got.Bytes(), want.Bytes()) || sum.Sum32() != wantSum.Sum32() {
+			t.Fatalf("level %d: ReadFrom output differs from Write", lvl)
+		}
+	}
+}
+
+// TestResetMidStream checks that Reset drops the data of an unfinished
+// stream.
+func TestResetMidStream(t *testing.T) {
+	data := opticks(t)
+	for _, lvl := range []int{HuffmanOnly, BestSpeed, 2, 6} {
+		var want, got bytes.Buffer
+		w, _ := NewWriter(&want, lvl)
+		w.Write(data[:1000])
+		w.Close()
+
+		w, _ = NewWriter(io.Discard, lvl)
+		w.Write(data[:100000])
+		w.Reset(&got)
+		w.Write(data[:1000])
+		w.Close()
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Fatalf("level %d: output after Reset differs", lvl)
+		}
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	data := opticks(t)
+	levels := []int{1, 2, 6, HuffmanOnly, 9, 1, NoCompression, 2, HuffmanOnly, 2, 1, 6, 0, 9}
+	for _, newWriter := range []func(io.Writer, int) (*Writer, error){NewWriter, NewWriterwWith4KWindow} {
+		var buf bytes.Buffer
+		w, _ := newWriter(&buf, levels[0])
+		sum := crc32.NewIEEE()
+		w.SetChecksum(sum)
+		chunk := len(data) / len(levels)
+		for i, lvl := range levels {
+			if err := w.SetLevel(lvl); err != nil {
+				t.Fatalf("SetLevel(%d): %v", lvl, err)
+			}
+			w.Write(data[i*chunk : (i+1)*chunk])
+		}
+		w.Close()
+		want := data[:len(levels)*chunk]
+		got, err := io.ReadAll(flate.NewReader(&buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) || sum.Sum32() != crc32.ChecksumIEEE(want) {
+			t.Fatal("round trip differs")
+		}
+	}
+}
+
+// TestSetLevelWindow checks that the data before a switch can be referred
+// to after it: a repeated chunk costs little after any switch, except to
+// and from HuffmanOnly. The Writer starts at HuffmanOnly, so that it keeps
+// the window of the standard library levels from the start.
+func TestSetLevelWindow(t *testing.T) {
+	chunk := opticks(t)[:20000]
+	for _, from := range []int{1, 2, 6} {
+		for _, to := range []int{1, 2, 6, 9} {
+			if from == to {
+				continue
+			}
+			var buf bytes.Buffer
+			w, _ := NewWriter(&buf, HuffmanOnly)
+			w.SetLevel(from)
+			w.Write(chunk)
+			if err := w.SetLevel(to); err != nil {
+				t.Fatal(err)
+			}
+			before := buf.Len()
+			w.Write(chunk)
+			w.Close()
+			if grown := buf.Len() - before; grown > 1000 {
+				t.Fatalf("%d to %d: repeated chunk took %d bytes", from, to, grown)
+			}
+			got, err := io.ReadAll(flate.NewReader(&buf))
+			if err != nil || !bytes.Equal(got, append(chunk[:len(chunk):len(chunk)], chunk...)) {
+				t.Fatalf("%d to %d: round trip differs: %v", from, to, err)
+			}
+		}
+	}
+}
+
+// TestSetLevelFirstSwitch checks that leaving a standard library level the
+// Writer was created with works without the earlier input, which the
+// Writer does not keep until SetLevel is called.
+func TestSetLevelFirstSwitch(t *testing.T) {
+	chunk := opticks(t)[:20000]
+	want := append(chunk[:len(chunk):len(chunk)], chunk...)
+	for _, to := range []int{HuffmanOnly, 1, 2, 9} {
+		for _, dict := range [][]byte{nil, chunk[:1000]} {
+			var buf bytes.Buffer
+			w, _ := NewWriterDict(&buf, 6, dict)
+			w.Write(chunk)
+			if len(w.recent) != 0 {
+				t.Fatalf("%d: Writer kept %d bytes before SetLevel", to, len(w.recent))
+			}
+			if err := w.SetLevel(to); err != nil {
+				t.Fatal(err)
+			}
+			w.Write(chunk)
+			w.Close()
+			got, err := io.ReadAll(flate.NewReaderDict(&buf, dict))
+			if err != nil || !bytes.Equal(got, want) {
+				t.Fatalf("6 to %d: round trip differs: %v", to, err)
+			}
+		}
+	}
+}
+
+// TestSetLevelUnstarted checks that SetLevel writes nothing before the
+// stream has started, and that the stream then matches that of a Writer made
+// at the new level.
+func TestSetLevelUnstarted(t *testing.T) {
+	data := opticks(t)[:50000]
+	for _, from := range []int{HuffmanOnly, 1, 6} {
+		for _, to := range []int{HuffmanOnly, 2, 9} {
+			var want, got bytes.Buffer
+			w, _ := NewWriter(&want, to)
+			w.Write(data)
+			w.Close()
+
+			w, _ = NewWriter(&got, from)
+			w.Write(data)
+			w.Reset(&got)
+			got.Reset()
+			if err := w.SetLevel(to); err != nil || got.Len() != 0 {
+				t.Fatalf("%d to %d: SetLevel wrote %d bytes, %v", from, to, got.Len(), err)
+			}
+			w.Write(data)
+			w.Close()
+			if !bytes.Equal(got.Bytes(), want.Bytes()) {
+				t.Fatalf("%d to %d: output differs from a new Writer", from, to)
+			}
+		}
+	}
+}
+
+func TestSetLevelReset(t *testing.T) {
+	data := opticks(t)[:50000]
+	for _, lvl := range []int{HuffmanOnly, 1, 2, 6} {
+		var want, got bytes.Buffer
+		w, _ := NewWriter(&want, lvl)
+		w.Write(data)
+		w.Close()
+
+		w, _ = NewWriter(io.Discard, 3)
+		w.Write(data)
+		w.SetLevel(lvl)
+		w.Write(data)
+		w.Reset(&got)
+		w.Write(data)
+		w.Close()
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Fatalf("level %d: output after SetLevel and Reset differs", lvl)
+		}
+	}
+}
+
+// TestSetLevelResetDict checks that a Writer made by NewWriterDict keeps
+// its dictionary across Reset after SetLevel moved it to an optimized level.
+func TestSetLevelResetDict(t *testing.T) {
+	dict := opticks(t)[:20000]
+	for _, lvl := range []int{1, 2} {
+		w, _ := NewWriterDict(io.Discard, 6, dict)
+		if err := w.SetLevel(lvl); err != nil {
+			t.Fatal(err)
+		}
+		w.Write(dict)
+		var buf bytes.Buffer
+		w.Reset(&buf)
+		w.Write(dict)
+		w.Close()
+		if buf.Len() > 1000 {
+			t.Fatalf("level %d: data equal to the dictionary took %d bytes", lvl, buf.Len())
+		}
+		got, err := io.ReadAll(flate.NewReaderDict(&buf, dict))
+		if err != nil || !bytes.Equal(got, dict) {
+			t.Fatalf("level %d: round trip differs: %v", lvl, err)
+		}
+	}
+}
+
+// TestSetLevelAllocs checks that switching back and forth between the
+// optimized compressors reuses them.
+func TestSetLevelAllocs(t *testing.T) {
+	data := opticks(t)[:10000]
+	w, _ := NewWriter(io.Discard, 1)
+	levels := []int{HuffmanOnly, 2, 1, HuffmanOnly, 1}
+	allocs := testing.AllocsPerRun(10, func() {
+		for _, lvl := range levels {
+			w.SetLevel(lvl)
+			w.Write(data)
+		}
+	})
+	if allocs > 0 {
+		t.Fatalf("SetLevel cycle made %v allocations", allocs)
+	}
+}
+
+func TestSetLevelErrors(t *testing.T) {
+	w, _ := NewWriter(io.Discard, 1)
+	if err := w.SetLevel(10); err == nil {
+		t.Fatal("accepted level 10")
+	}
+	w.Rsyncable(true)
+	if err := w.SetLevel(6); err != errRsyncableLevel {
+		t.Fatalf("SetLevel(6) in rsyncable mode = %v", err)
+	}
+	if err := w.SetLevel(HuffmanOnly); err != nil {
+		t.Fatalf("SetLevel(HuffmanOnly) in rsyncable mode = %v", err)
+	}
+}
+
 func diff(d, s []byte) (pos int) {
 	pos = -1
 	for i := 0; i < len(d); i++ {
@@ -127,3 +366,57 @@ func BenchmarkDynamicCompress(b *testing.B) {
 		}
 	}
 }
+
+// BenchmarkStdlibLevel measures level 6 through the Writer against the
+// standard library writer it wraps, in 4 KB writes.
+func BenchmarkStdlibLevel(b *testing.B) {
+	data := opticks(b)
+	run := func(b *testing.B, w io.WriteCloser, reset func()) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < len(data); j += 4096 {
+				end := j + 4096
+				if end > len(data) {
+					end = len(data)
+				}
+				w.Write(data[j:end])
+			}
+			w.Close()
+			reset()
+		}
+	}
+	b.Run("fastgo", func(b *testing.B) {
+		w, _ := NewWriter(io.Discard, 6)
+		run(b, w, func() { w.Reset(io.Discard) })
+	})
+	b.Run("std", func(b *testing.B) {
+		w, _ := flate.NewWriter(io.Discard, 6)
+		run(b, w, func() { w.Reset(io.Discard) })
+	})
+}
+
+// TestHuffmanOnlyFlush checks that flushing a HuffmanOnly writer mid-stream
+// does not emit the final padding of the stream, which would corrupt the
+// blocks that follow.
+func TestHuffmanOnlyFlush(t *testing.T) {
+	data := opticks(t)
+	buf := bytes.NewBuffer(nil)
+	w, _ := NewWriter(buf, HuffmanOnly)
+	for i := 0; i < len(data); i += 7777 {
+		end := i + 7777
+		if end > len(data) {
+			end = len(data)
+		}
+		w.Write(data[i:end])
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(flate.NewReader(buf))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("round trip: %d of %d bytes, %v", len(got), len(data), err)
+	}
+}
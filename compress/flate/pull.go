@@ -0,0 +1,140 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package flate
+
+import (
+	"bytes"
+	"hash"
+	"io"
+)
+
+// pullBufferSize is the size of the buffer through which a CompressingReader
+// feeds a standard library writer, which takes its input from Write.
+const pullBufferSize = 32 * 1024
+
+// A CompressingReader compresses the data of a source reader and returns
+// the DEFLATE stream from its Read method. It is for APIs that take a body
+// to send as an io.Reader, which otherwise need an io.Pipe and a goroutine
+// running a Writer. No goroutine is involved: Read pulls input from the
+// source straight into the compressor's window, as Writer.ReadFrom does,
+// until some compressed data is ready.
+//
+// A read from the source that returns less than asked for is taken to mean
+// that no more input is available for now, and the data read so far is
+// flushed, as Writer.Flush does, so that Read does not wait for a slow
+// source to fill a whole block. Sources that always return short reads,
+// such as a byte at a time, thus compress poorly.
+type CompressingReader struct {
+	src  io.Reader
+	fw   *Writer
+	once pullOnce
+	out  bytes.Buffer // compressed data not read yet
+	err  error        // returned once out is drained
+}
+
+// NewCompressingReader returns a CompressingReader compressing the data
+// read from src at the given level, which is checked like by NewWriter.
+func NewCompressingReader(src io.Reader, level int) (*CompressingReader, error) {
+	return NewCompressingReaderDict(src, level, nil)
+}
+
+// NewCompressingReaderDict is like NewCompressingReader but compresses
+// with a preset dictionary, as NewWriterDict does.
+func NewCompressingReaderDict(src io.Reader, level int, dict []byte) (*CompressingReader, error) {
+	r := &CompressingReader{src: src}
+	fw, err := NewWriterDict(&r.out, level, dict)
+	if err != nil {
+		return nil, err
+	}
+	r.fw = fw
+	return r, nil
+}
+
+// Read reads compressed data. It returns io.EOF once the whole stream,
+// ending with the data read from src before its io.EOF, has been read.
+// Errors of src are returned as is and end the stream.
+func (r *CompressingReader) Read(p []byte) (int, error) {
+	for r.out.Len() == 0 && r.err == nil {
+		r.err = r.pull()
+		if r.err == io.EOF {
+			if err := r.fw.Close(); err != nil {
+				r.err = err
+			}
+		}
+	}
+	if r.out.Len() > 0 {
+		return r.out.Read(p)
+	}
+	return 0, r.err
+}
+
+// Reset discards the state of r and makes it compress the data read from
+// src, reusing its compressor.
+func (r *CompressingReader) Reset(src io.Reader) {
+	r.src = src
+	r.out.Reset()
+	r.err = nil
+	r.fw.Reset(&r.out)
+}
+
+// SetChecksum makes r feed the data it reads from its source to sum, as
+// Writer.SetChecksum does. Reset does not change or reset sum.
+func (r *CompressingReader) SetChecksum(sum hash.Hash32) {
+	r.fw.SetChecksum(sum)
+}
+
+// pull feeds the compressor with one read from the source, flushes it if
+// the read was short, and returns io.EOF once the source has ended.
+func (r *CompressingReader) pull() error {
+	r.once.reset(r.src)
+	if _, err := r.fw.ReadFrom(&r.once); err != nil {
+		return err
+	}
+	if r.once.err != nil {
+		return r.once.err
+	}
+	if r.once.n > 0 && r.once.n < r.once.asked {
+		return r.fw.Flush()
+	}
+	return nil
+}
+
+// pullOnce is the reader pull gives to Writer.ReadFrom. It reads from the
+// source once, then reports io.EOF, keeping the outcome of that read.
+type pullOnce struct {
+	src   io.Reader
+	buf   []byte // for WriteTo, kept across pulls
+	done  bool
+	n     int   // bytes read from src
+	asked int   // bytes asked of src
+	err   error // error of src
+}
+
+func (o *pullOnce) reset(src io.Reader) {
+	o.src, o.done, o.n, o.asked, o.err = src, false, 0, 0, nil
+}
+
+func (o *pullOnce) Read(p []byte) (int, error) {
+	if o.done {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	o.done = true
+	o.asked = len(p)
+	o.n, o.err = o.src.Read(p)
+	return o.n, nil
+}
+
+// WriteTo serves the writers that take their input from Write, through
+// io.Copy in Writer.ReadFrom, without a new buffer at each pull.
+func (o *pullOnce) WriteTo(w io.Writer) (int64, error) {
+	if o.buf == nil {
+		o.buf = make([]byte, pullBufferSize)
+	}
+	n, _ := o.Read(o.buf)
+	n, err := w.Write(o.buf[:n])
+	return int64(n), err
+}
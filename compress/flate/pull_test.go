@@ -0,0 +1,153 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package flate
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+// pullTestData returns n bytes of compressible text with some noise.
+func pullTestData(n int) []byte {
+	rnd := rand.New(rand.NewSource(1))
+	words := []string{"deflate ", "window ", "match ", "literal ", "block ", "\n"}
+	var b bytes.Buffer
+	for b.Len() < n {
+		if rnd.Intn(8) == 0 {
+			b.WriteByte(byte(rnd.Intn(256)))
+			continue
+		}
+		b.WriteString(words[rnd.Intn(len(words))])
+	}
+	return b.Bytes()[:n]
+}
+
+func TestCompressingReader(t *testing.T) {
+	data := pullTestData(1 << 20)
+	sources := map[string]func(r io.Reader) io.Reader{
+		"plain":   func(r io.Reader) io.Reader { return r },
+		"half":    iotest.HalfReader,
+		"onebyte": iotest.OneByteReader,
+		"dataerr": iotest.DataErrReader,
+	}
+	levels := []int{HuffmanOnly, DefaultCompression, NoCompression, BestSpeed, 2, BestCompression}
+	for name, src := range sources {
+		size := len(data)
+		if name == "onebyte" {
+			size = 100 << 10
+		}
+		for _, level := range levels {
+			r, err := NewCompressingReader(src(bytes.NewReader(data[:size])), level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(flate.NewReader(r))
+			if err != nil {
+				t.Fatalf("%s, level %d: %v", name, level, err)
+			}
+			if !bytes.Equal(got, data[:size]) {
+				t.Fatalf("%s, level %d: round trip differs", name, level)
+			}
+		}
+	}
+}
+
+// TestCompressingReaderMatchesWriter checks that the stream read from a
+// source that fills every read, but the last one, is the one Writer makes
+// with a Flush for the short read before Close.
+func TestCompressingReaderMatchesWriter(t *testing.T) {
+	data := pullTestData(300 << 10)
+	for _, level := range []int{BestSpeed, 2} {
+		var want bytes.Buffer
+		w, _ := NewWriter(&want, level)
+		w.Write(data)
+		w.Flush()
+		w.Close()
+		r, _ := NewCompressingReader(bytes.NewReader(data), level)
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want.Bytes()) {
+			t.Fatalf("level %d: %d bytes, Writer made %d", level, len(got), want.Len())
+		}
+	}
+}
+
+// stallReader returns data in one read, then fails the test if it is read
+// again.
+type stallReader struct {
+	t    *testing.T
+	data []byte
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	if s.data == nil {
+		s.t.Fatal("source read again before the compressed data was returned")
+	}
+	n := copy(p, s.data)
+	s.data = nil
+	return n, nil
+}
+
+// TestCompressingReaderShortRead checks that Read returns what a short
+// read of the source gave instead of waiting for more input.
+func TestCompressingReaderShortRead(t *testing.T) {
+	data := pullTestData(1000)
+	for _, level := range []int{HuffmanOnly, BestSpeed, 2, BestCompression} {
+		r, _ := NewCompressingReader(&stallReader{t: t, data: data}, level)
+		buf := make([]byte, 4096)
+		n, err := r.Read(buf)
+		if err != nil || n == 0 {
+			t.Fatalf("level %d: Read = %d, %v", level, n, err)
+		}
+		// The flushed data decodes to the whole input.
+		got := make([]byte, len(data))
+		if _, err := io.ReadFull(flate.NewReader(bytes.NewReader(buf[:n])), got); err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("level %d: flushed data: %v", level, err)
+		}
+	}
+}
+
+func TestCompressingReaderEmpty(t *testing.T) {
+	r, err := NewCompressingReader(bytes.NewReader(nil), BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(flate.NewReader(r))
+	if err != nil || len(got) != 0 {
+		t.Fatalf("got %d bytes, %v", len(got), err)
+	}
+}
+
+func TestCompressingReaderError(t *testing.T) {
+	errSrc := errors.New("source failed")
+	src := io.MultiReader(bytes.NewReader(pullTestData(100<<10)), iotest.ErrReader(errSrc))
+	r, _ := NewCompressingReader(src, BestSpeed)
+	if _, err := io.ReadAll(r); err != errSrc {
+		t.Fatalf("got %v, want %v", err, errSrc)
+	}
+	if n, err := r.Read(make([]byte, 10)); n != 0 || err != errSrc {
+		t.Fatalf("Read after error = %d, %v", n, err)
+	}
+
+	// Reset clears the error.
+	data := pullTestData(10 << 10)
+	r.Reset(bytes.NewReader(data))
+	got, err := io.ReadAll(flate.NewReader(r))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("after Reset: %d bytes, %v", len(got), err)
+	}
+}
+
+func TestCompressingReaderLevel(t *testing.T) {
+	if _, err := NewCompressingReader(nil, 10); err == nil {
+		t.Fatal("accepted level 10")
+	}
+}
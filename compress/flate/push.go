@@ -0,0 +1,140 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package flate
+
+import (
+	"errors"
+	"hash"
+	"io"
+)
+
+// ErrTrailingData is returned by Inflater.Write for input past the end of
+// the DEFLATE stream.
+var ErrTrailingData = errors.New("flate: data after end of stream")
+
+// errNeedInput is returned by pushInput once the written data is used up.
+var errNeedInput = errors.New("need input")
+
+// pushInput holds the compressed data given to Inflater.Write while the
+// decompressor reads it.
+type pushInput struct {
+	buf    []byte
+	closed bool // no more data will come
+}
+
+func (in *pushInput) Read(p []byte) (int, error) {
+	if len(in.buf) == 0 {
+		if in.closed {
+			return 0, io.EOF
+		}
+		return 0, errNeedInput
+	}
+	n := copy(p, in.buf)
+	in.buf = in.buf[n:]
+	return n, nil
+}
+
+// An Inflater decompresses a DEFLATE stream written to it and writes the
+// decompressed data to a destination writer. It is the push counterpart of
+// NewReader, for event-driven code that receives compressed data in pieces
+// and has no io.Reader to give: each Write decodes as much as the input
+// allows, hands the output to the destination and returns without waiting
+// for more.
+//
+// The destination gets the output straight from the history buffer, so it
+// must not keep the slices it is given.
+type Inflater struct {
+	dst io.Writer
+	in  pushInput
+	f   decompressor
+	err error // sticky error
+}
+
+// NewInflater returns an Inflater writing the decompressed data to dst.
+func NewInflater(dst io.Writer) *Inflater {
+	return NewInflaterDict(dst, nil)
+}
+
+// NewInflaterDict is like NewInflater but uses a preset dictionary, like
+// NewReaderDict.
+func NewInflaterDict(dst io.Writer, dict []byte) *Inflater {
+	z := &Inflater{}
+	z.Reset(dst, dict)
+	return z
+}
+
+// Reset discards the state of z and makes it decompress a new stream to
+// dst, with dict as the preset dictionary. A checksum set with SetChecksum
+// is kept.
+func (z *Inflater) Reset(dst io.Writer, dict []byte) {
+	z.dst = dst
+	z.in = pushInput{}
+	z.err = nil
+	z.f.Reset(&z.in, dict)
+}
+
+// SetChecksum makes z feed the decompressed data to sum before writing it
+// to the destination. A nil sum turns this off.
+func (z *Inflater) SetChecksum(sum hash.Hash32) {
+	z.f.SetChecksum(sum)
+}
+
+// Write decompresses p and writes the data that it completes to the
+// destination. Once the end of the stream is reached, the rest of p is not
+// used: Write returns the number of bytes that belonged to the stream and
+// ErrTrailingData, as it does for any later non-empty write. Corrupt input
+// and errors of the destination are returned and stop z.
+func (z *Inflater) Write(p []byte) (n int, err error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.Done() {
+		if len(p) > 0 {
+			return 0, ErrTrailingData
+		}
+		return 0, nil
+	}
+	z.in.buf = p
+	err = z.run()
+	n = len(p) - len(z.in.buf)
+	z.in.buf = nil
+	if err != nil {
+		return n, err
+	}
+	if z.Done() {
+		// The bytes after the stream stay buffered by the decompressor.
+		n -= z.f.rBuf.Buffered()
+		if n < len(p) {
+			return n, ErrTrailingData
+		}
+	}
+	return n, nil
+}
+
+// Close tells z that the input has ended. It returns io.ErrUnexpectedEOF
+// if the stream is not complete. It does not close the destination.
+func (z *Inflater) Close() error {
+	if z.err != nil || z.Done() {
+		return z.err
+	}
+	z.in.closed = true
+	return z.run()
+}
+
+// Done reports whether the end of the stream has been reached and all the
+// decompressed data has been written to the destination.
+func (z *Inflater) Done() bool {
+	return z.f.state.phase == phaseFinish
+}
+
+// run decodes the input until it runs out or the stream ends, and keeps
+// the errors that stop z.
+func (z *Inflater) run() error {
+	_, err := z.f.WriteTo(z.dst)
+	if err == errNeedInput {
+		return nil
+	}
+	z.err = err
+	return err
+}
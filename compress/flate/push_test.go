@@ -0,0 +1,169 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package flate
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// compressStd compresses data with the standard library at the given level.
+func compressStd(t *testing.T, data, dict []byte, level int) []byte {
+	var buf bytes.Buffer
+	w, err := flate.NewWriterDict(&buf, level, dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data[:len(data)/3])
+	w.Flush()
+	w.Write(data[len(data)/3:])
+	w.Close()
+	return buf.Bytes()
+}
+
+// pushPieces writes in to z in pieces of random size up to max.
+func pushPieces(z *Inflater, in []byte, max int, rnd *rand.Rand) error {
+	for len(in) > 0 {
+		n := 1 + rnd.Intn(max)
+		if n > len(in) {
+			n = len(in)
+		}
+		m, err := z.Write(in[:n])
+		if err != nil {
+			return err
+		}
+		if m != n {
+			return io.ErrShortWrite
+		}
+		in = in[n:]
+	}
+	return z.Close()
+}
+
+func TestInflater(t *testing.T) {
+	data := pullTestData(300 << 10)
+	rnd := rand.New(rand.NewSource(1))
+	for _, level := range []int{NoCompression, HuffmanOnly, BestSpeed, DefaultCompression, BestCompression} {
+		in := compressStd(t, data, nil, level)
+		for _, max := range []int{1, 7, 1000, 100000} {
+			if max == 1 && level != BestSpeed {
+				continue
+			}
+			var out bytes.Buffer
+			z := NewInflater(&out)
+			if err := pushPieces(z, in, max, rnd); err != nil {
+				t.Fatalf("level %d, pieces of %d: %v", level, max, err)
+			}
+			if !z.Done() || !bytes.Equal(out.Bytes(), data) {
+				t.Fatalf("level %d, pieces of %d: round trip differs", level, max)
+			}
+		}
+	}
+}
+
+func TestInflaterDict(t *testing.T) {
+	data := pullTestData(10 << 10)
+	dict := data[:2000]
+	in := compressStd(t, data, dict, BestSpeed)
+	var out bytes.Buffer
+	z := NewInflaterDict(&out, dict)
+	if err := pushPieces(z, in, 100, rand.New(rand.NewSource(1))); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatal("round trip differs")
+	}
+}
+
+func TestInflaterTrailingData(t *testing.T) {
+	data := pullTestData(10 << 10)
+	in := compressStd(t, data, nil, BestSpeed)
+	var out bytes.Buffer
+	z := NewInflater(&out)
+	n, err := z.Write(append(in[:len(in):len(in)], "trailer"...))
+	if err != ErrTrailingData || n != len(in) {
+		t.Fatalf("Write = %d, %v, want %d, %v", n, err, len(in), ErrTrailingData)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatal("round trip differs")
+	}
+	if n, err := z.Write([]byte("more")); n != 0 || err != ErrTrailingData {
+		t.Fatalf("Write after end = %d, %v", n, err)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Reset starts a new stream.
+	out.Reset()
+	z.Reset(&out, nil)
+	if err := pushPieces(z, in, 1000, rand.New(rand.NewSource(1))); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatal("round trip after Reset differs")
+	}
+}
+
+func TestInflaterErrors(t *testing.T) {
+	data := pullTestData(100 << 10)
+	in := compressStd(t, data, nil, BestSpeed)
+
+	z := NewInflater(io.Discard)
+	if _, err := z.Write(in[:len(in)-10]); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Close(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Close of a truncated stream = %v", err)
+	}
+
+	z.Reset(io.Discard, nil)
+	if _, err := z.Write([]byte{0xff, 0xff, 0xff, 0xff}); err == nil {
+		t.Fatal("no error for corrupt input")
+	}
+	if _, err := z.Write(in); err == nil {
+		t.Fatal("error is not sticky")
+	}
+
+	errSink := errors.New("sink failed")
+	z.Reset(failWriter{errSink}, nil)
+	if _, err := z.Write(in); err != errSink {
+		t.Fatalf("got %v, want %v", err, errSink)
+	}
+}
+
+type failWriter struct{ err error }
+
+func (w failWriter) Write(p []byte) (int, error) { return 0, w.err }
+
+// TestInflaterFlush checks that the data before a flush is written out
+// without waiting for more input.
+func TestInflaterFlush(t *testing.T) {
+	data := pullTestData(50 << 10)
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, BestSpeed)
+	w.Write(data[:20000])
+	w.Flush()
+	var out bytes.Buffer
+	z := NewInflater(&out)
+	if _, err := z.Write(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), data[:20000]) {
+		t.Fatalf("got %d bytes after the flush, want 20000", out.Len())
+	}
+	buf.Reset()
+	w.Write(data[20000:])
+	w.Close()
+	if _, err := z.Write(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if !z.Done() || !bytes.Equal(out.Bytes(), data) {
+		t.Fatal("round trip differs")
+	}
+}
@@ -12,7 +12,10 @@ import (
 	"compress/flate"
 	"encoding/binary"
 	"errors"
+	"hash"
 	"io"
+
+	"github.com/intel/fastgo/internal/sumcopy"
 )
 
 // Type aliases for compatibility with standard library
@@ -35,6 +38,23 @@ var (
 	errOutputOverflow = errors.New("output overflow") // Output buffer overflow
 )
 
+// Checksummer is implemented by Writer and by the ReadCloser returned by
+// NewReader. SetChecksum makes them feed the uncompressed data passing through
+// them to sum while they copy it, a few kilobytes at a time, so that sum
+// reads the data back from cache rather than from memory.
+type Checksummer interface {
+	SetChecksum(sum hash.Hash32)
+}
+
+// readError wraps an error of the underlying reader met before any input
+// was consumed. It is not kept by the decompressor, so that reading can go
+// on if the error was temporary, like a network timeout.
+type readError struct {
+	err error
+}
+
+func (e readError) Error() string { return e.err.Error() }
+
 // isError checks if the error is one of the decompression-specific errors
 func isError(err error) bool {
 	if err == errInvalidBlock || err == errInvalidSymbol || err == errInvalidLookBack {
@@ -43,9 +63,16 @@ func isError(err error) bool {
 	return false
 }
 
-// NewReaderDict creates a new Reader with a preset dictionary.
-// Currently delegates to standard library implementation.
-var NewReaderDict = flate.NewReaderDict
+// NewReaderDict is like NewReader but initializes the reader with a preset
+// dictionary. The returned reader behaves as if the uncompressed data
+// stream started with the given dictionary, which has already been read.
+// NewReaderDict is typically used to read data compressed by
+// NewWriterDict.
+func NewReaderDict(r io.Reader, dict []byte) io.ReadCloser {
+	rr := &decompressor{}
+	rr.Reset(r, dict)
+	return rr
+}
 
 // NewReader creates a new Intel-optimized DEFLATE decompressor that reads from r.
 // The decompressor automatically detects whether to use Intel optimizations
@@ -70,11 +97,14 @@ type decompressor struct {
 	err           error                            // Last error encountered
 	peekSize      int                              // Size of data available for peeking
 	eof           bool                             // End of file flag
+	starved       bool                             // Last step ran out of input
+	sum           hash.Hash32                      // Checksum of the output, may be nil
 }
 
-// Reset resets the decompressor to read from a new underlying Reader.
-// The optional dictionary parameter is currently ignored for compatibility.
-func (r *decompressor) Reset(under io.Reader, _ []byte) error {
+// Reset resets the decompressor to read from a new underlying Reader,
+// with dict as the preset dictionary. Only the last 32KB of dict can be
+// referred to and are kept.
+func (r *decompressor) Reset(under io.Reader, dict []byte) error {
 	r.r = under
 	if ur, ok := under.(*bufio.Reader); ok {
 		r.rBuf = ur
@@ -86,13 +116,33 @@ func (r *decompressor) Reset(under io.Reader, _ []byte) error {
 		}
 	}
 
+	if len(dict) > historySize {
+		dict = dict[len(dict)-historySize:]
+	}
 	r.peekSize = 0
+	r.writePos = copy(r.historyBuffer[:], dict)
+	r.readPos = r.writePos
 	r.eof = false
+	r.starved = false
 	r.err = nil
 	r.state.reset()
 	return nil
 }
 
+// SetChecksum makes the decompressor feed all data returned by Read or
+// WriteTo to sum, right after copying it out of the history buffer.
+// A nil sum turns this off. Reset does not change or reset sum.
+func (r *decompressor) SetChecksum(sum hash.Hash32) {
+	r.sum = sum
+}
+
+// copyOut copies pending decompressed data to b and feeds it to f.sum.
+func (f *decompressor) copyOut(b []byte) (n int) {
+	n = sumcopy.Copy(b, f.historyBuffer[f.readPos:f.writePos], f.sum)
+	f.readPos += n
+	return n
+}
+
 // Close closes the decompressor. Currently a no-op as no resources need cleanup.
 func (r *decompressor) Close() error {
 	return nil
@@ -105,8 +155,7 @@ func (f *decompressor) Read(b []byte) (n int, err error) {
 	for {
 		// If we have decompressed data available, copy it to the output buffer
 		if f.writePos-f.readPos > 0 {
-			num := copy(b, f.historyBuffer[f.readPos:f.writePos])
-			f.readPos += num
+			num := f.copyOut(b)
 			n += num
 			if f.writePos == f.readPos {
 				return n, f.err
@@ -119,12 +168,49 @@ func (f *decompressor) Read(b []byte) (n int, err error) {
 		}
 		// Process more input data
 		f.err = f.step()
+		if re, ok := f.err.(readError); ok {
+			f.err = nil
+			return n, re.err
+		}
 		if f.err != nil && f.writePos-f.readPos == 0 {
 			return n, f.err
 		}
 	}
 }
 
+// WriteTo implements io.WriterTo. It hands decompressed data to w straight
+// from the history buffer, saving the copy into a caller's buffer that Read
+// makes. It returns when the stream ends or an error occurs.
+func (f *decompressor) WriteTo(w io.Writer) (n int64, err error) {
+	for {
+		if f.writePos-f.readPos > 0 {
+			if f.sum != nil {
+				f.sum.Write(f.historyBuffer[f.readPos:f.writePos])
+			}
+			num, err := w.Write(f.historyBuffer[f.readPos:f.writePos])
+			f.readPos += num
+			n += int64(num)
+			if err != nil {
+				return n, err
+			}
+			if f.readPos != f.writePos {
+				return n, io.ErrShortWrite
+			}
+		}
+		if f.err != nil {
+			if f.err == io.EOF {
+				return n, nil
+			}
+			return n, f.err
+		}
+		f.err = f.step()
+		if re, ok := f.err.(readError); ok {
+			f.err = nil
+			return n, re.err
+		}
+	}
+}
+
 func (f *decompressor) step() (err error) {
 	state := &f.state
 
@@ -133,13 +219,25 @@ func (f *decompressor) step() (err error) {
 	}
 
 	if state.input == nil {
-		state.input, err = f.rBuf.Peek(f.rBuf.Size())
-		f.peekSize = len(state.input)
-		if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
-			return err
+		// Decode what is buffered, and only read once the decoder ran out
+		// of it, so that data flushed by the writer comes out without
+		// waiting for more input, as on a network connection.
+		skip := int(state.bitsLen / 8)
+		n := f.rBuf.Buffered()
+		if n <= skip && f.starved {
+			n = skip + 1
+		}
+		state.input, err = f.rBuf.Peek(n)
+		if err != nil && err != io.EOF {
+			state.input = nil
+			return readError{err}
 		}
+		if err == nil {
+			state.input, _ = f.rBuf.Peek(f.rBuf.Buffered())
+		}
+		f.peekSize = len(state.input)
 		f.eof = err == io.EOF
-		state.input = state.input[f.state.bitsLen/8:]
+		state.input = state.input[skip:]
 	}
 	f.readPos = f.writePos
 
@@ -153,6 +251,7 @@ func (f *decompressor) step() (err error) {
 	startInputSize, startBitsLen := len(f.state.input), int(f.state.bitsLen)
 	err = f.decomperss()
 	f.state.rOffset(startInputSize, startBitsLen)
+	f.starved = err == errEndInput
 
 	if isError(err) || (err == errEndInput && f.eof) {
 		discardSize := f.peekSize - len(f.state.input) - int(state.bitsLen/8)
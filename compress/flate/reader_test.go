@@ -10,12 +10,16 @@ package flate
 import (
 	"bytes"
 	"compress/flate"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 // TestNlitOutOfRange tests handling of invalid DEFLATE data with out-of-range nlit values.
@@ -104,3 +108,358 @@ func doBench(b *testing.B, f func(b *testing.B, buf []byte, level, n int)) {
 		}
 	}
 }
+
+func TestReaderDict(t *testing.T) {
+	var dict []byte
+	for i := 0; i < 40000; i++ {
+		dict = append(dict, "dictionary words "[i%17]+byte(i/5000))
+	}
+	data := append(append([]byte{}, dict[len(dict)-20000:]...), dict[len(dict)-30000:]...)
+	data = append(data, bytes.Repeat([]byte("tail of the data "), 3000)...)
+	// The standard library ignores the dictionary at BestSpeed.
+	for _, level := range []int{2, DefaultCompression, BestCompression} {
+		var buf bytes.Buffer
+		w, _ := flate.NewWriterDict(&buf, level, dict)
+		w.Write(data)
+		w.Close()
+		compressed := buf.Bytes()
+
+		got, err := io.ReadAll(NewReaderDict(bytes.NewReader(compressed), dict))
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("level %d: NewReaderDict: %d bytes, %v", level, len(got), err)
+		}
+		r := NewReader(bytes.NewReader(nil))
+		r.(Resetter).Reset(bytes.NewReader(compressed), dict)
+		got, err = io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("level %d: Reset: %d bytes, %v", level, len(got), err)
+		}
+		// Without the dictionary the back references are invalid.
+		r.(Resetter).Reset(bytes.NewReader(compressed), nil)
+		if _, err = io.ReadAll(r); err == nil {
+			t.Fatalf("level %d: decoded without the dictionary", level)
+		}
+	}
+}
+
+// TestInvalidLookBack checks that a back reference to before the start of
+// the output is reported as corrupt input, in the fast loop as well as in
+// the slow one, and that no bytes past it are returned.
+func TestInvalidLookBack(t *testing.T) {
+	dict := make([]byte, 32*1024)
+	for i := range dict {
+		dict[i] = byte(i * 7 / 3)
+	}
+	// Make the stream start with a reference into the dictionary, then
+	// enough literals that the fast loop is used for the first block.
+	var data []byte
+	for i := 0; i < 64*1024; i++ {
+		data = append(data, byte(i*i>>3))
+	}
+	for _, prefix := range [][]byte{nil, []byte("some literals first")} {
+		src := append(append(append([]byte{}, prefix...), dict[100:400]...), data...)
+		var buf bytes.Buffer
+		w, _ := flate.NewWriterDict(&buf, BestCompression, dict)
+		w.Write(src)
+		w.Close()
+
+		got, err := io.ReadAll(NewReader(bytes.NewReader(buf.Bytes())))
+		if _, ok := err.(CorruptInputError); !ok {
+			t.Fatalf("prefix %q: got %v, want CorruptInputError", prefix, err)
+		}
+		if len(got) > len(prefix) || !bytes.HasPrefix(src, got) {
+			t.Fatalf("prefix %q: returned %d bytes past the invalid reference", prefix, len(got)-len(prefix))
+		}
+	}
+}
+
+// fixedBlock builds a final DEFLATE block with the fixed Huffman codes.
+type fixedBlock struct {
+	out   []byte
+	bits  uint32
+	nbits uint
+}
+
+// put writes the n-bit Huffman code c, most significant bit first.
+func (b *fixedBlock) put(c uint32, n uint) {
+	for i := int(n) - 1; i >= 0; i-- {
+		b.bits |= (c >> uint(i) & 1) << b.nbits
+		if b.nbits++; b.nbits == 8 {
+			b.out = append(b.out, byte(b.bits))
+			b.bits, b.nbits = 0, 0
+		}
+	}
+}
+
+// literal writes a literal byte below 144.
+func (b *fixedBlock) literal(c byte) { b.put(0x30+uint32(c), 8) }
+
+// bytes ends the block with enough padding for the fast loop to run.
+func (b *fixedBlock) bytes() []byte {
+	b.put(0, 8)
+	return append(b.out, make([]byte, 64)...)
+}
+
+func newFixedBlock() *fixedBlock {
+	b := &fixedBlock{}
+	b.put(1, 1) // BFINAL
+	b.put(2, 2) // BTYPE 01, least significant bit first
+	return b
+}
+
+// TestCorruptPrefix checks that the output decoded before an invalid
+// symbol or distance is returned in full, and that a distance 1 reference at the start
+// of the stream still returns nothing past it.
+func TestCorruptPrefix(t *testing.T) {
+	var prefix []byte
+	for i := 0; i < 5000; i++ {
+		prefix = append(prefix, 'a'+byte(i*i>>4)%26)
+	}
+	b := newFixedBlock()
+	for _, c := range prefix {
+		b.literal(c)
+	}
+	b.put(0xc6, 8) // symbol 286, which has no meaning
+	got, err := io.ReadAll(NewReader(bytes.NewReader(b.bytes())))
+	if _, ok := err.(CorruptInputError); !ok {
+		t.Fatalf("invalid symbol: got %v, want CorruptInputError", err)
+	}
+	if !bytes.Equal(got, prefix) {
+		t.Fatalf("invalid symbol: returned %d bytes of the %d before it", len(got), len(prefix))
+	}
+
+	// Short enough to be in the first read, so that the fast path meets
+	// the invalid distance.
+	short := prefix[:2000]
+	b = newFixedBlock()
+	for _, c := range short {
+		b.literal(c)
+	}
+	b.put(0x01, 7)    // length 3
+	b.put(0x1d, 5)    // distance code 29
+	b.put(0x1fff, 13) // distance 32768, past the start of the stream
+	got, err = io.ReadAll(NewReader(bytes.NewReader(b.bytes())))
+	if _, ok := err.(CorruptInputError); !ok {
+		t.Fatalf("invalid distance: got %v, want CorruptInputError", err)
+	}
+	if !bytes.Equal(got, short) {
+		t.Fatalf("invalid distance: returned %d bytes of the %d before it", len(got), len(short))
+	}
+
+	b = newFixedBlock()
+	b.put(0x01, 7) // length 3
+	b.put(0x00, 5) // distance 1
+	for _, c := range prefix {
+		b.literal(c)
+	}
+	got, err = io.ReadAll(NewReader(bytes.NewReader(b.bytes())))
+	if _, ok := err.(CorruptInputError); !ok || len(got) != 0 {
+		t.Fatalf("distance 1 at the start: %d bytes, %v", len(got), err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	data := bytes.Repeat([]byte("verify this stream\n"), 10000)
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, BestSpeed)
+	w.Write(data)
+	w.Close()
+	compressed := buf.Len()
+	buf.WriteString("trailing")
+
+	rep, err := Verify(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if rep.UncompressedSize != int64(len(data)) || rep.CompressedSize != int64(compressed) {
+		t.Fatalf("Verify = %+v, want sizes %d and %d", rep, compressed, len(data))
+	}
+
+	if _, err := Verify(bytes.NewReader(buf.Bytes()[:compressed/2])); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Verify truncated: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	corrupt := append([]byte(nil), buf.Bytes()[:compressed]...)
+	corrupt[0] |= 0x06 // reserved block type
+	rep, err = Verify(bytes.NewReader(corrupt))
+	if _, ok := err.(CorruptInputError); !ok || rep.ErrOffset != int64(err.(CorruptInputError)) {
+		t.Fatalf("Verify corrupt = %+v, %v", rep, err)
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	data := bytes.Repeat([]byte("write to\n"), 50000)
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, BestSpeed)
+	w.Write(data)
+	w.Close()
+
+	var out bytes.Buffer
+	n, err := NewReader(&buf).(io.WriterTo).WriteTo(&out)
+	if err != nil || n != int64(len(data)) || !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("WriteTo = %d, %v; want %d, nil", n, err, len(data))
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	var data []byte
+	for i := 0; i < 100000; i++ {
+		data = append(data, byte(i*i>>5), byte(i))
+	}
+	want := crc32.ChecksumIEEE(data)
+	for _, level := range []int{HuffmanOnly, BestSpeed, 2, DefaultCompression, BestCompression} {
+		var buf bytes.Buffer
+		sum := crc32.NewIEEE()
+		w, _ := NewWriter(&buf, level)
+		w.SetChecksum(sum)
+		for p := data; len(p) > 0; {
+			n := 7777
+			if n > len(p) {
+				n = len(p)
+			}
+			w.Write(p[:n])
+			p = p[n:]
+		}
+		w.Close()
+		if got := sum.Sum32(); got != want {
+			t.Fatalf("level %d: writer checksum = %08x, want %08x", level, got, want)
+		}
+		compressed := buf.Bytes()
+
+		sum.Reset()
+		r := NewReader(bytes.NewReader(compressed))
+		r.(Checksummer).SetChecksum(sum)
+		if _, err := io.CopyBuffer(io.Discard, struct{ io.Reader }{r}, make([]byte, 1000)); err != nil {
+			t.Fatalf("level %d: Read: %v", level, err)
+		}
+		if got := sum.Sum32(); got != want {
+			t.Fatalf("level %d: Read checksum = %08x, want %08x", level, got, want)
+		}
+
+		sum.Reset()
+		r = NewReader(bytes.NewReader(compressed))
+		r.(Checksummer).SetChecksum(sum)
+		if _, err := r.(io.WriterTo).WriteTo(io.Discard); err != nil {
+			t.Fatalf("level %d: WriteTo: %v", level, err)
+		}
+		if got := sum.Sum32(); got != want {
+			t.Fatalf("level %d: WriteTo checksum = %08x, want %08x", level, got, want)
+		}
+	}
+}
+
+// TestReadAfterFlush checks that data flushed by the writer can be read
+// without more input, as on a connection where the rest has not been sent.
+func TestReadAfterFlush(t *testing.T) {
+	errWait := errors.New("no more input yet")
+	for _, level := range []int{BestSpeed, 2, DefaultCompression, BestCompression} {
+		var buf bytes.Buffer
+		w, _ := NewWriter(&buf, level)
+		var msgs [][]byte
+		var ends []int
+		for i := 0; i < 100; i++ {
+			msg := []byte(fmt.Sprintf("message %d %s\n", i, strings.Repeat("x", i*i)))
+			w.Write(msg)
+			w.Flush()
+			msgs = append(msgs, msg)
+			ends = append(ends, buf.Len())
+		}
+		w.Close()
+
+		src := &flakyReader{data: buf.Bytes(), err: errWait}
+		r := NewReader(src)
+		for i, msg := range msgs {
+			src.stop = ends[i]
+			got := make([]byte, len(msg))
+			if n, err := io.ReadFull(r, got); err != nil || !bytes.Equal(got, msg) {
+				t.Fatalf("level %d: message %d: got %d of %d bytes, %v", level, i, n, len(msg), err)
+			}
+		}
+		src.stop = len(src.data)
+		if n, err := r.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+			t.Fatalf("level %d: Read at the end = %d, %v; want 0, EOF", level, n, err)
+		}
+	}
+}
+
+// TestReaderShortReads checks that the stream decodes the same whatever
+// the sizes of the reads of the underlying reader, and that input cut short
+// anywhere gives a prefix of the data and io.ErrUnexpectedEOF.
+func TestReaderShortReads(t *testing.T) {
+	data := []byte(strings.Repeat("short reads of the compressed input\n", 2000))
+	for i := 0; i < len(data); i += 37 {
+		data[i] = byte(i)
+	}
+	for _, level := range []int{HuffmanOnly, BestSpeed, 2, BestCompression} {
+		var buf bytes.Buffer
+		w, _ := NewWriter(&buf, level)
+		w.Write(data)
+		w.Close()
+		compressed := buf.Bytes()
+
+		for name, wrap := range map[string]func(io.Reader) io.Reader{
+			"onebyte": iotest.OneByteReader,
+			"half":    iotest.HalfReader,
+			"dataerr": iotest.DataErrReader,
+		} {
+			got, err := io.ReadAll(NewReader(wrap(bytes.NewReader(compressed))))
+			if err != nil || !bytes.Equal(got, data) {
+				t.Fatalf("level %d, %s: got %d bytes, %v", level, name, len(got), err)
+			}
+		}
+
+		for cut := 0; cut < len(compressed); cut += 1 + cut/4 {
+			got, err := io.ReadAll(NewReader(iotest.HalfReader(bytes.NewReader(compressed[:cut]))))
+			if err != io.ErrUnexpectedEOF || !bytes.HasPrefix(data, got) {
+				t.Fatalf("level %d, cut at %d of %d: got %d bytes, %v", level, cut, len(compressed), len(got), err)
+			}
+		}
+	}
+}
+
+// TestReadErrorNotSticky checks that an error of the underlying reader met
+// between blocks does not end the stream.
+func TestReadErrorNotSticky(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, BestSpeed)
+	w.Write([]byte("first"))
+	w.Flush()
+	first := buf.Len()
+	w.Write([]byte("second"))
+	w.Close()
+
+	errTemp := errors.New("temporary")
+	src := &flakyReader{data: buf.Bytes(), stop: first, err: errTemp}
+	r := NewReader(src)
+	got := make([]byte, 5)
+	if _, err := io.ReadFull(r, got); err != nil || string(got) != "first" {
+		t.Fatalf("first = %q, %v", got, err)
+	}
+	if _, err := r.Read(got); err != errTemp {
+		t.Fatalf("Read = %v, want %v", err, errTemp)
+	}
+	src.stop = len(src.data)
+	if rest, err := io.ReadAll(r); err != nil || string(rest) != "second" {
+		t.Fatalf("rest = %q, %v", rest, err)
+	}
+}
+
+// flakyReader returns err once it reaches stop.
+type flakyReader struct {
+	data []byte
+	pos  int
+	stop int
+	err  error
+}
+
+func (f *flakyReader) Read(p []byte) (int, error) {
+	if f.pos >= len(f.data) {
+		return 0, io.EOF
+	}
+	if f.pos >= f.stop {
+		return 0, f.err
+	}
+	n := copy(p, f.data[f.pos:f.stop])
+	f.pos += n
+	return n, nil
+}
@@ -0,0 +1,352 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package flate
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// A Splicer concatenates complete DEFLATE streams into a single stream
+// without recompressing them, like zlib's gzjoin example.
+//
+// Each stream is decoded to find where its last block starts and where the
+// stream ends, and its compressed bytes are copied to the output as they
+// are, except for the BFINAL bit of its last block, which is cleared. Every
+// stream but the last is followed by an empty stored block, which brings
+// the output to a byte boundary for the next stream at a cost of five
+// bytes. The result decodes to the concatenation of the inputs.
+//
+// In BenchmarkSplice, splicing is about twice as fast as decompressing the
+// streams and compressing their data again at BestSpeed, and some 50 times
+// as fast at BestCompression.
+//
+// A stream may not refer back to data before its own start, so streams
+// compressed with a preset dictionary cannot be spliced.
+type Splicer struct {
+	out    spliceWriter
+	in     spliceReader
+	closed bool
+	err    error
+}
+
+// NewSplicer returns a Splicer writing the combined stream to w.
+func NewSplicer(w io.Writer) *Splicer {
+	s := &Splicer{}
+	s.out.w = w
+	return s
+}
+
+// Reset discards the state of s and makes it equivalent to the result of
+// NewSplicer, but writing to w instead. Its buffers and decoder are kept.
+func (s *Splicer) Reset(w io.Writer) {
+	s.out.w, s.out.buf, s.out.err = w, s.out.buf[:0], nil
+	s.closed, s.err = false, nil
+}
+
+// Add appends the DEFLATE stream read from r and returns its uncompressed
+// size. A *bufio.Reader is read through Peek and Discard, and is left right
+// after the stream, as is an io.Seeker, which is read through a
+// bufio.Reader and sought back over what was read past the stream. Any
+// other io.ByteReader is read a byte at a time, which is much slower, so
+// that no more than the stream is consumed. Other readers may be read past
+// the end of the stream. Corrupt input is reported as a CorruptInputError
+// holding the offset in r.
+func (s *Splicer) Add(r io.Reader) (int64, error) {
+	return s.add(r, false)
+}
+
+func (s *Splicer) add(r io.Reader, last bool) (int64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	if s.closed {
+		return 0, errors.New("flate: Add after Close")
+	}
+	s.in.reset(r, &s.out)
+	if s.err = s.in.stream(last); s.err == nil {
+		s.err = s.in.finish(last)
+	}
+	if s.err == nil {
+		s.err = s.out.err
+	}
+	if last && s.err == nil {
+		s.closed = true
+		s.err = s.out.flush()
+	}
+	return s.in.size, s.err
+}
+
+// Close ends the combined stream with an empty final block and flushes it
+// to the underlying writer. It does not close the underlying writer.
+func (s *Splicer) Close() error {
+	if s.err != nil || s.closed {
+		return s.err
+	}
+	s.closed = true
+	// An empty fixed Huffman block: BFINAL, BTYPE 01 and the 7-bit
+	// all-zero end-of-block code.
+	s.out.buf = append(s.out.buf, 1|1<<1, 0)
+	s.err = s.out.flush()
+	return s.err
+}
+
+// Splice writes to w a single DEFLATE stream that decodes to the
+// concatenation of the complete DEFLATE streams read from srcs.
+// See Splicer for how this is done without recompression.
+func Splice(w io.Writer, srcs ...io.Reader) error {
+	s := NewSplicer(w)
+	if len(srcs) == 0 {
+		return s.Close()
+	}
+	for i, r := range srcs {
+		if _, err := s.add(r, i == len(srcs)-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// spliceBufferSize is the amount of output a Splicer buffers before writing
+// it to the underlying writer.
+const spliceBufferSize = 64 * 1024
+
+// spliceWriter buffers the combined stream, which is at a byte boundary
+// between streams.
+type spliceWriter struct {
+	w   io.Writer
+	buf []byte
+	err error
+}
+
+func (b *spliceWriter) write(p []byte) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) >= spliceBufferSize {
+		b.flush()
+	}
+}
+
+// end writes the last byte of a stream, of which only the n low bits
+// belong to the stream, 0 <= n < 8. Unless last is set, they are followed
+// by an empty stored block: a zero BFINAL bit and BTYPE, padding up to a
+// byte boundary, and LEN 0 with its complement.
+func (b *spliceWriter) end(c byte, n uint, last bool) {
+	c &= 1<<n - 1
+	if last {
+		if n > 0 {
+			b.buf = append(b.buf, c)
+		}
+		return
+	}
+	b.buf = append(b.buf, c)
+	if n+3 > 8 {
+		b.buf = append(b.buf, 0)
+	}
+	b.buf = append(b.buf, 0, 0, 0xff, 0xff)
+}
+
+func (b *spliceWriter) flush() error {
+	if len(b.buf) > 0 && b.err == nil {
+		_, b.err = b.w.Write(b.buf)
+	}
+	b.buf = b.buf[:0]
+	return b.err
+}
+
+// spliceLazySize is the size beyond which the input kept for a source read
+// a byte at a time is trimmed.
+const spliceLazySize = 4096
+
+// spliceReader decodes one DEFLATE stream with the inflate state machine,
+// discarding the output, and copies its input to out.
+type spliceReader struct {
+	out  *spliceWriter
+	br   *bufio.Reader // source, read through Peek and Discard
+	sbr  *bufio.Reader // reused for sources that are not bufio.Readers
+	seek io.Seeker     // source behind br, sought back to the end of the stream
+	byt  io.ByteReader // source read a byte at a time instead of br
+
+	f *decompressor // decoder, allocated on first use
+
+	in      []byte // input, starting at offset base of the source
+	pos     int    // bytes of in given to the decoder
+	base    int64
+	mark    int64 // offset up to which the input was copied to out
+	headers int   // block headers seen
+	final   int64 // bit offset of the BFINAL bit to clear, or -1
+	size    int64 // uncompressed size so far
+	err     error
+}
+
+// reset prepares s to walk the stream read from r.
+func (s *spliceReader) reset(r io.Reader, out *spliceWriter) {
+	s.out, s.br, s.seek, s.byt = out, nil, nil, nil
+	s.in, s.pos, s.base, s.mark = s.in[:0], 0, 0, 0
+	s.headers, s.final, s.size, s.err = 0, -1, 0, nil
+	if br, ok := r.(*bufio.Reader); ok {
+		s.br = br
+		return
+	}
+	if sk, ok := r.(io.Seeker); ok {
+		if _, err := sk.Seek(0, io.SeekCurrent); err == nil {
+			s.seek = sk
+		}
+	}
+	if br, ok := r.(io.ByteReader); ok && s.seek == nil {
+		s.byt = br
+		return
+	}
+	if s.sbr == nil {
+		s.sbr = bufio.NewReader(r)
+	} else {
+		s.sbr.Reset(r)
+	}
+	s.br = s.sbr
+}
+
+// offset returns the bit offset in the source of the next bit the decoder
+// consumes.
+func (s *spliceReader) offset() int64 {
+	return (s.base+int64(s.pos))*8 - int64(s.f.state.bitsLen)
+}
+
+// stream decodes one stream, clearing the BFINAL bit of its last block
+// unless last is set.
+func (s *spliceReader) stream(last bool) error {
+	if s.f == nil {
+		s.f = &decompressor{}
+	}
+	f, state := s.f, &s.f.state
+	state.reset()
+	f.readPos, f.writePos = 0, 0
+	for {
+		if f.writePos >= historySize*2 {
+			copy(f.historyBuffer[:historySize], f.historyBuffer[f.writePos-historySize:f.writePos])
+			f.writePos = historySize
+		}
+		f.readPos = f.writePos
+		state.input = s.in[s.pos:]
+		err := f.decomperss()
+		s.size += int64(f.writePos - f.readPos)
+		s.pos = len(s.in) - len(state.input)
+		if state.headers != s.headers && !last {
+			// A block started in this call, and may be the last one.
+			// Clearing the BFINAL bit of the others does not change them.
+			s.headers = state.headers
+			end := s.base + int64(len(s.in))
+			s.final = (end-int64(state.hdrInput))*8 - int64(state.hdrBits)
+		}
+		switch {
+		case isError(err):
+			return s.corrupt()
+		case state.phase == phaseStreamEnd:
+			return nil
+		case err == errEndInput:
+			if !s.next() {
+				return s.err
+			}
+		}
+	}
+}
+
+// next reads more input from the source and reports whether it got any.
+// The input is copied to out first, up to the next block header that was
+// not started yet, as the bytes before it may be dropped.
+func (s *spliceReader) next() bool {
+	if s.err != nil {
+		return false
+	}
+	if s.f.state.phase == phaseNewBlock {
+		s.copyTo(s.offset() >> 3)
+	} else {
+		s.copyTo(s.base + int64(s.pos))
+	}
+	keep := len(s.in) - int(s.mark-s.base)
+	if s.byt != nil {
+		if len(s.in) >= spliceLazySize {
+			drop := len(s.in) - keep
+			s.in = s.in[:copy(s.in, s.in[drop:])]
+			s.pos -= drop
+			s.base += int64(drop)
+		}
+		c, err := s.byt.ReadByte()
+		if err != nil {
+			s.fail(err)
+			return false
+		}
+		s.in = append(s.in, c)
+		return true
+	}
+	drop := len(s.in) - keep
+	s.br.Discard(drop) // buffered already
+	s.base += int64(drop)
+	s.pos -= drop
+	_, err := s.br.Peek(keep + 1)
+	s.in, _ = s.br.Peek(s.br.Buffered())
+	if err != nil {
+		s.fail(err)
+		return false
+	}
+	return true
+}
+
+// copyTo copies the input up to offset off to out, clearing the BFINAL bit
+// at s.final on the way.
+func (s *spliceReader) copyTo(off int64) {
+	if off <= s.mark {
+		return
+	}
+	data := s.in[s.mark-s.base : off-s.base]
+	if i := s.final>>3 - s.mark; s.final >= 0 && i >= 0 && i < int64(len(data)) {
+		s.out.write(data[:i])
+		s.out.write([]byte{data[i] &^ (1 << uint(s.final&7))})
+		data = data[i+1:]
+		s.final = -1
+	}
+	s.out.write(data)
+	s.mark = off
+}
+
+// finish copies the end of the stream to out, followed by an empty stored
+// block unless last is set, and leaves the source right after the stream.
+func (s *spliceReader) finish(last bool) error {
+	end := s.offset()
+	s.copyTo(end >> 3)
+	n := uint(end & 7)
+	var c byte
+	if n > 0 {
+		c = s.in[s.mark-s.base]
+		if s.final >= 0 && s.final>>3 == s.mark {
+			c &^= 1 << uint(s.final&7)
+		}
+	}
+	s.out.end(c, n, last)
+	if s.byt != nil {
+		return nil
+	}
+	s.br.Discard(int((end+7)>>3 - s.base))
+	if s.seek != nil {
+		if _, err := s.seek.Seek(-int64(s.br.Buffered()), io.SeekCurrent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *spliceReader) fail(err error) {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *spliceReader) corrupt() error {
+	if s.err == nil {
+		s.err = CorruptInputError(s.offset() >> 3)
+	}
+	return s.err
+}
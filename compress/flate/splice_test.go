@@ -0,0 +1,258 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package flate
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// spliceInputs returns DEFLATE streams covering every block type, at
+// various bit offsets, with the data they decode to.
+func spliceInputs(t *testing.T) (streams [][]byte, want []byte) {
+	rnd := rand.New(rand.NewSource(1))
+	for i, level := range []int{BestSpeed, NoCompression, HuffmanOnly, 2, 5, BestCompression, BestSpeed, NoCompression} {
+		data := make([]byte, rnd.Intn(100000))
+		for j := range data {
+			data[j] = "abcdefgh"[rnd.Intn(8)] + byte(j%(i+1))
+		}
+		if i == 3 {
+			data = data[:0]
+		}
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+		if i%2 == 0 {
+			w.Flush() // adds an empty stored block
+		}
+		w.Close()
+		streams = append(streams, buf.Bytes())
+		want = append(want, data...)
+	}
+	return streams, want
+}
+
+func TestSplice(t *testing.T) {
+	streams, want := spliceInputs(t)
+	var srcs []io.Reader
+	for _, s := range streams {
+		srcs = append(srcs, bytes.NewReader(s))
+	}
+	var out bytes.Buffer
+	if err := Splice(&out, srcs...); err != nil {
+		t.Fatalf("Splice: %v", err)
+	}
+	for name, r := range map[string]io.Reader{
+		"fastgo": NewReader(bytes.NewReader(out.Bytes())),
+		"std":    flate.NewReader(bytes.NewReader(out.Bytes())),
+	} {
+		got, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("%s: decoded %d bytes, %v; want %d bytes", name, len(got), err, len(want))
+		}
+	}
+
+	// Add and Close give the same data, ended by an empty block.
+	out.Reset()
+	s := NewSplicer(&out)
+	var total int64
+	for _, stream := range streams {
+		n, err := s.Add(bytes.NewReader(stream))
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		total += n
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if total != int64(len(want)) {
+		t.Fatalf("Add sizes sum to %d, want %d", total, len(want))
+	}
+	got, err := io.ReadAll(flate.NewReader(&out))
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("Splicer: decoded %d bytes, %v; want %d bytes", len(got), err, len(want))
+	}
+
+	out.Reset()
+	if err := Splice(&out); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(flate.NewReader(&out)); err != nil || len(got) != 0 {
+		t.Fatalf("Splice of nothing: %q, %v", got, err)
+	}
+}
+
+// TestSpliceSmall splices streams of a few bits, whose block headers and
+// ends share bytes, from each kind of source.
+func TestSpliceSmall(t *testing.T) {
+	var streams [][]byte
+	var want []byte
+	for i := 0; i < 12; i++ {
+		data := bytes.Repeat([]byte{'a' + byte(i)}, i%4)
+		var buf bytes.Buffer
+		w, _ := flate.NewWriter(&buf, BestSpeed)
+		w.Write(data)
+		w.Close()
+		streams = append(streams, buf.Bytes())
+		want = append(want, data...)
+	}
+	for _, kind := range []string{"bufio", "bytereader"} {
+		var srcs []io.Reader
+		for _, stream := range streams {
+			src := bytes.NewReader(stream)
+			if kind == "bufio" {
+				srcs = append(srcs, bufio.NewReaderSize(src, 16))
+			} else {
+				srcs = append(srcs, byteReader{src, src})
+			}
+		}
+		var out bytes.Buffer
+		if err := Splice(&out, srcs...); err != nil {
+			t.Fatalf("%s: Splice: %v", kind, err)
+		}
+		got, err := io.ReadAll(flate.NewReader(&out))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("%s: decoded %q, %v; want %q", kind, got, err, want)
+		}
+	}
+}
+
+// byteReader hides the io.Seeker of a bytes.Reader.
+type byteReader struct {
+	io.Reader
+	io.ByteReader
+}
+
+// TestSpliceSources checks that Add leaves each kind of source it reads
+// exactly right after the stream, except plain readers.
+func TestSpliceSources(t *testing.T) {
+	streams, want := spliceInputs(t)
+	const trailer = "trailer"
+	for _, kind := range []string{"bufio", "seeker", "bytereader", "reader"} {
+		var out bytes.Buffer
+		s := NewSplicer(&out)
+		for i, stream := range streams {
+			src := bytes.NewReader(append(stream[:len(stream):len(stream)], trailer...))
+			var r io.Reader
+			switch kind {
+			case "bufio":
+				r = bufio.NewReaderSize(src, 16)
+			case "seeker":
+				r = src
+			case "bytereader":
+				r = byteReader{src, src}
+			case "reader":
+				r = struct{ io.Reader }{src}
+			}
+			if _, err := s.Add(r); err != nil {
+				t.Fatalf("%s: Add %d: %v", kind, i, err)
+			}
+			if kind == "reader" {
+				continue
+			}
+			if rest, err := io.ReadAll(r); err != nil || string(rest) != trailer {
+				t.Fatalf("%s: stream %d followed by %q, %v; want %q", kind, i, rest, err, trailer)
+			}
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("%s: Close: %v", kind, err)
+		}
+		got, err := io.ReadAll(flate.NewReader(&out))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("%s: decoded %d bytes, %v; want %d bytes", kind, len(got), err, len(want))
+		}
+	}
+}
+
+func TestSpliceErrors(t *testing.T) {
+	streams, _ := spliceInputs(t)
+	truncated := streams[0][:len(streams[0])/2]
+	if err := Splice(io.Discard, bytes.NewReader(streams[1]), bytes.NewReader(truncated)); err != io.ErrUnexpectedEOF {
+		t.Fatalf("truncated: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	// A stream that relies on a preset dictionary cannot be spliced.
+	var buf bytes.Buffer
+	w, _ := flate.NewWriterDict(&buf, BestCompression, []byte("a dictionary of words"))
+	w.Write([]byte("words of a dictionary"))
+	w.Close()
+	if err := Splice(io.Discard, &buf); err == nil {
+		t.Fatal("dictionary stream: got nil error")
+	} else if _, ok := err.(CorruptInputError); !ok {
+		t.Fatalf("dictionary stream: got %v, want CorruptInputError", err)
+	}
+}
+
+func TestSpliceReset(t *testing.T) {
+	streams, want := spliceInputs(t)
+	s := NewSplicer(io.Discard)
+	s.Add(bytes.NewReader(streams[0][:len(streams[0])/2])) // fails
+	var out bytes.Buffer
+	s.Reset(&out)
+	for _, st := range streams {
+		if _, err := s.Add(bytes.NewReader(st)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(flate.NewReader(&out))
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("after Reset: decoded %d bytes, %v; want %d bytes", len(got), err, len(want))
+	}
+}
+
+// BenchmarkSplice compares splicing streams with decompressing them and
+// compressing the concatenation again, at the level they were made with.
+func BenchmarkSplice(b *testing.B) {
+	data := opticks(b)
+	for _, level := range []int{BestSpeed, 2, DefaultCompression, BestCompression} {
+		var streams [][]byte
+		for i := 0; i < 4; i++ {
+			var buf bytes.Buffer
+			w, _ := NewWriter(&buf, level)
+			w.Write(data)
+			w.Close()
+			streams = append(streams, buf.Bytes())
+		}
+		size := int64(4 * len(data))
+		b.Run(fmt.Sprintf("level%d/splice", level), func(b *testing.B) {
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				var srcs []io.Reader
+				for _, s := range streams {
+					srcs = append(srcs, bufio.NewReader(bytes.NewReader(s)))
+				}
+				if err := Splice(io.Discard, srcs...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("level%d/recompress", level), func(b *testing.B) {
+			b.SetBytes(size)
+			w, _ := NewWriter(io.Discard, level)
+			r := NewReader(nil)
+			for i := 0; i < b.N; i++ {
+				w.Reset(io.Discard)
+				for _, s := range streams {
+					r.(Resetter).Reset(bytes.NewReader(s), nil)
+					if _, err := io.Copy(w, r); err != nil {
+						b.Fatal(err)
+					}
+				}
+				w.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package flate
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/intel/fastgo/internal/iocount"
+)
+
+// A Report summarizes a stream checked by Verify.
+type Report struct {
+	CompressedSize   int64 // compressed bytes consumed
+	UncompressedSize int64 // bytes the stream decompresses to
+	ErrOffset        int64 // offset in the compressed input of the first error
+}
+
+// Verify decompresses the DEFLATE stream read from r and discards the output
+// without copying it out of the decoder, which makes it cheaper than reading
+// the stream into io.Discard. Raw DEFLATE carries no checksum, so only the
+// structure of the stream is checked.
+//
+// If the stream is damaged, Verify returns the error Read would return and
+// Report.ErrOffset holds the offset where decoding stopped. Verify may read
+// past the end of the stream.
+func Verify(r io.Reader) (Report, error) {
+	cr := &iocount.Reader{R: r}
+	br := bufio.NewReader(cr)
+	f := &decompressor{}
+	f.Reset(br, nil)
+	n, err := f.WriteTo(io.Discard)
+	rep := Report{
+		CompressedSize:   cr.N - int64(br.Buffered()),
+		UncompressedSize: n,
+	}
+	if err != nil {
+		rep.ErrOffset = rep.CompressedSize
+		if off, ok := err.(CorruptInputError); ok {
+			rep.ErrOffset = int64(off)
+		}
+	}
+	return rep, err
+}
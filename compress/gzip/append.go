@@ -0,0 +1,176 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+// appendScanChunk is the size of the window used when scanning an existing
+// file backwards for the header of its last member.
+const appendScanChunk = 32 * 1024
+
+// minMemberSize is the size of the smallest possible gzip member: a 10-byte
+// header, an empty final fixed Huffman block and the 8-byte trailer.
+const minMemberSize = 10 + 2 + 8
+
+var gzipMagic = []byte{gzipID1, gzipID2, gzipDeflate}
+
+// NewAppendWriter checks that rws holds a well-formed gzip file and returns
+// a Writer that appends a new member after the existing data.
+//
+// An empty rws is accepted and is written from the start. Otherwise the
+// last member of the file must decode cleanly and its trailer must end
+// exactly at the end of rws; this catches the truncated tail left behind
+// when a previous writer was interrupted before Close. If verify is true,
+// every member is decompressed and checked, not only the last one.
+//
+// On success rws is positioned at its end. The returned Writer behaves as if
+// created by NewWriterLevel and its Header may be set before the first write.
+func NewAppendWriter(rws io.ReadWriteSeeker, level int, verify bool) (*Writer, error) {
+	if level < HuffmanOnly || level > BestCompression {
+		return NewWriterLevel(rws, level)
+	}
+	size, err := rws.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if size > 0 {
+		if verify {
+			err = verifyMembers(rws)
+		} else {
+			err = verifyLastMember(rws, size)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err = rws.Seek(0, io.SeekEnd); err != nil {
+			return nil, err
+		}
+	}
+	return NewWriterLevel(rws, level)
+}
+
+// OpenAppend opens the named gzip file for appending, creating it if it does
+// not exist, and returns a Writer that adds a new member to it.
+// See NewAppendWriter for the checks done on existing content.
+//
+// The caller must Close the Writer before closing the returned file.
+func OpenAppend(name string, level int, verify bool) (*Writer, *os.File, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	z, err := NewAppendWriter(f, level, verify)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return z, f, nil
+}
+
+// verifyMembers decodes every member of rs from its start and reports the
+// first error found.
+func verifyMembers(rs io.ReadSeeker) error {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	z, err := NewReader(rs)
+	if err != nil {
+		return noEOF(err)
+	}
+	_, err = io.Copy(io.Discard, z)
+	return err
+}
+
+// errNotLast is returned by tryLastMember for a member that decodes
+// cleanly but is followed by more data.
+var errNotLast = errors.New("gzip: member is not the last one")
+
+// verifyLastMember scans rs backwards for a gzip header from which a single
+// member decodes up to exactly size bytes.
+//
+// The scan stops at the first member that decodes cleanly: if it does not
+// end at size, what follows it is damaged, as any member header after it
+// was tried already. So only the last members are decoded, rather than
+// the whole file when its tail is corrupt.
+func verifyLastMember(rs io.ReadSeeker, size int64) error {
+	var (
+		z       Reader
+		br      = bufio.NewReader(rs)
+		buf     = make([]byte, appendScanChunk+len(gzipMagic)-1)
+		lastErr error // error of the candidate closest to the end
+	)
+	end := size - minMemberSize + 1
+	for end > 0 {
+		start := end - appendScanChunk
+		if start < 0 {
+			start = 0
+		}
+		// Read a few bytes past end so that headers straddling the
+		// boundary with the next chunk are seen exactly once.
+		last := end + int64(len(gzipMagic)) - 1
+		if last > size {
+			last = size
+		}
+		chunk := buf[:last-start]
+		if _, err := rs.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(rs, chunk); err != nil {
+			return noEOF(err)
+		}
+		for i := len(chunk); ; {
+			i = bytes.LastIndex(chunk[:i], gzipMagic)
+			if i < 0 {
+				break
+			}
+			if start+int64(i) >= end {
+				continue
+			}
+			switch err := tryLastMember(&z, br, rs, start+int64(i)); {
+			case err == nil:
+				return nil
+			case err == errNotLast:
+				// What follows this member is damaged, and no member
+				// before it can be the last one.
+				if lastErr == nil {
+					return ErrHeader
+				}
+				return lastErr
+			case lastErr == nil:
+				lastErr = err
+			}
+		}
+		end = start
+	}
+	if lastErr == nil {
+		return ErrHeader
+	}
+	return lastErr
+}
+
+// tryLastMember reports whether a single gzip member starting at off
+// decodes without error and ends exactly at the end of rs.
+func tryLastMember(z *Reader, br *bufio.Reader, rs io.ReadSeeker, off int64) error {
+	if _, err := rs.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	br.Reset(rs)
+	if err := z.Reset(br); err != nil {
+		return noEOF(err)
+	}
+	z.Multistream(false)
+	if _, err := io.Copy(io.Discard, z); err != nil {
+		return err
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		return errNotLast
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func appendSession(t *testing.T, name string, payload []byte, verify bool) error {
+	t.Helper()
+	w, f, err := OpenAppend(name, BestSpeed, verify)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := w.Write(payload); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return nil
+}
+
+func TestOpenAppend(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "log.gz")
+	var want []byte
+	for i, verify := range []bool{false, true, false} {
+		payload := bytes.Repeat([]byte{'a' + byte(i)}, 1000*(i+1))
+		if err := appendSession(t, name, payload, verify); err != nil {
+			t.Fatalf("session %d: %v", i, err)
+		}
+		want = append(want, payload...)
+	}
+
+	compressed, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(want))
+	}
+}
+
+func TestOpenAppendTruncated(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "log.gz")
+	if err := appendSession(t, name, []byte("first session\n"), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendSession(t, name, []byte("second session\n"), false); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a crash that lost the end of the last member.
+	if err := os.Truncate(name, fi.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+	for _, verify := range []bool{false, true} {
+		if err := appendSession(t, name, []byte("third session\n"), verify); err == nil {
+			t.Errorf("verify=%v: append to truncated file succeeded", verify)
+		}
+	}
+}
+
+func TestNewAppendWriterCorruptMiddle(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write([]byte("hello, world\n"))
+	w.Close()
+	firstLen := buf.Len()
+	w.Reset(&buf)
+	w.Write([]byte("goodbye\n"))
+	w.Close()
+	data := buf.Bytes()
+	// Corrupt the CRC of the first member only.
+	data[firstLen-8] ^= 0xff
+
+	rws := &seekBuffer{data: data}
+	if _, err := NewAppendWriter(rws, DefaultCompression, false); err != nil {
+		t.Fatalf("quick check: %v", err)
+	}
+	if _, err := NewAppendWriter(rws, DefaultCompression, true); err != ErrChecksum {
+		t.Fatalf("full check: got %v, want %v", err, ErrChecksum)
+	}
+}
+
+func TestNewAppendWriterCorruptTail(t *testing.T) {
+	// Many members of incompressible data, the last one truncated.
+	var buf bytes.Buffer
+	chunk := make([]byte, 8*1024)
+	rnd := rand.New(rand.NewSource(1))
+	w := NewWriter(&buf)
+	for i := 0; i < 128; i++ {
+		rnd.Read(chunk)
+		w.Reset(&buf)
+		w.Write(chunk)
+		w.Close()
+	}
+	size := buf.Len()
+	rws := &seekBuffer{data: buf.Bytes()[:size-3]}
+	if _, err := NewAppendWriter(rws, DefaultCompression, false); err != io.ErrUnexpectedEOF {
+		t.Fatalf("got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	// Only the last members are decoded.
+	if rws.read > int64(size)/8 {
+		t.Fatalf("read %d bytes of a %d-byte file", rws.read, size)
+	}
+}
+
+// seekBuffer is an in-memory io.ReadWriteSeeker.
+type seekBuffer struct {
+	data []byte
+	off  int
+	read int64 // bytes read
+}
+
+func (s *seekBuffer) Read(p []byte) (int, error) {
+	if s.off >= len(s.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, s.data[s.off:])
+	s.off += n
+	s.read += int64(n)
+	return n, nil
+}
+
+func (s *seekBuffer) Write(p []byte) (int, error) {
+	s.data = append(s.data[:s.off], p...)
+	s.off += len(p)
+	return len(p), nil
+}
+
+func (s *seekBuffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += int64(s.off)
+	case io.SeekEnd:
+		offset += int64(len(s.data))
+	}
+	s.off = int(offset)
+	return offset, nil
+}
@@ -0,0 +1,497 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/intel/fastgo/internal/iocount"
+)
+
+var errNotSeekable = errors.New("gzip: underlying file does not support seeking")
+
+// NewFS returns a file system that serves the files of fsys, and in
+// addition the decompressed content of every gzip file of fsys under its
+// name without the .gz suffix: foo.txt is read from foo.txt.gz unless
+// foo.txt exists itself. Data such as test fixtures or configuration
+// bundles can so stay compressed in an embed.FS.
+//
+// The returned file system implements fs.StatFS and fs.ReadDirFS.
+// Directories list foo.txt in place of foo.txt.gz, which can still be
+// opened under its own name. The size of a decompressed file is counted by
+// decompressing it, as the ISIZE field of a gzip trailer only holds the size
+// of its own member modulo 4 GB. An open file does so from the last member
+// it has found, and then knows where every member starts.
+//
+// Decompressed files implement io.Seeker and io.ReaderAt only when their
+// gzip file does, so that callers such as http.ServeContent can check for
+// these interfaces. An open file records where each gzip member starts as it gets
+// there, and Seek and ReadAt start decompressing from the last member start
+// before the offset, skipping over data from there. A member cannot be
+// entered in the middle, so within one member, such as the whole of a
+// single-member file, seeking backward starts over from the beginning of
+// the member; reading in order is cheapest. Files written as many small
+// members, with Writer.NewMember for instance, are cheap to read at random
+// once each member has been reached.
+func NewFS(fsys fs.FS) fs.FS {
+	return &gzFS{fsys: fsys}
+}
+
+type gzFS struct {
+	fsys fs.FS
+}
+
+func (g *gzFS) Open(name string) (fs.File, error) {
+	f, err := g.fsys.Open(name)
+	if err == nil {
+		if info, serr := f.Stat(); serr == nil && info.IsDir() {
+			return &dir{File: f, fsys: g, name: name}, nil
+		}
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) || name == "." {
+		return nil, err
+	}
+	gz, gerr := g.fsys.Open(name + ".gz")
+	if gerr != nil {
+		return nil, err
+	}
+	if info, serr := gz.Stat(); serr != nil || info.IsDir() {
+		gz.Close()
+		return nil, err
+	}
+	gf := &file{fsys: g, name: name, gz: gz}
+	gf.idx.cps = []checkpoint{{}}
+	gf.rd.idx = &gf.idx
+	if zerr := gf.rd.start(gz, 0, 0); zerr != nil {
+		gz.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: zerr}
+	}
+	_, seeker := gz.(io.Seeker)
+	_, readerAt := gz.(io.ReaderAt)
+	switch {
+	case seeker && readerAt:
+		return seekReaderAtFile{gf}, nil
+	case seeker:
+		return seekerFile{gf}, nil
+	case readerAt:
+		return readerAtFile{gf}, nil
+	}
+	return gf, nil
+}
+
+func (g *gzFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := fs.Stat(g.fsys, name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) || name == "." {
+		return info, err
+	}
+	gzInfo, gerr := fs.Stat(g.fsys, name+".gz")
+	if gerr != nil || gzInfo.IsDir() {
+		return nil, err
+	}
+	return g.statGzip(name, gzInfo)
+}
+
+// statGzip returns the FileInfo of the decompressed file name, whose gzip
+// file is described by gzInfo.
+func (g *gzFS) statGzip(name string, gzInfo fs.FileInfo) (fs.FileInfo, error) {
+	size, err := uncompressedSize(g.fsys, name+".gz")
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return newFileInfo(gzInfo, size), nil
+}
+
+func (g *gzFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(g.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		exists[e.Name()] = true
+	}
+	for i, e := range entries {
+		base := strings.TrimSuffix(e.Name(), ".gz")
+		if !e.IsDir() && base != e.Name() && base != "" && !exists[base] {
+			entries[i] = &dirEntry{DirEntry: e, fsys: g, name: base, path: joinPath(name, base)}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func joinPath(dir, name string) string {
+	if dir == "." {
+		return name
+	}
+	return dir + "/" + name
+}
+
+// uncompressedSize returns the size of the content of the gzip file name.
+func uncompressedSize(fsys fs.FS, name string) (int64, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	z, err := NewReader(bufio.NewReader(f))
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(io.Discard, z)
+}
+
+// fileInfo describes a decompressed file by the FileInfo of its gzip file.
+type fileInfo struct {
+	fs.FileInfo
+	name string
+	size int64
+}
+
+func newFileInfo(gzInfo fs.FileInfo, size int64) *fileInfo {
+	return &fileInfo{FileInfo: gzInfo, name: strings.TrimSuffix(gzInfo.Name(), ".gz"), size: size}
+}
+
+func (fi *fileInfo) Name() string { return fi.name }
+func (fi *fileInfo) Size() int64  { return fi.size }
+
+// dirEntry lists a gzip file under its decompressed name.
+type dirEntry struct {
+	fs.DirEntry
+	fsys *gzFS
+	name string
+	path string // of the decompressed file in fsys
+}
+
+func (e *dirEntry) Name() string { return e.name }
+
+func (e *dirEntry) Info() (fs.FileInfo, error) {
+	gzInfo, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return e.fsys.statGzip(e.path, gzInfo)
+}
+
+// dir is an open directory, listed like gzFS.ReadDir does.
+type dir struct {
+	fs.File
+	fsys    *gzFS
+	name    string
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.listed = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// A checkpoint is a place where decompression can start: the offset of a
+// gzip member in the gzip file and that of its first byte in the file.
+type checkpoint struct {
+	off int64
+	pos int64
+}
+
+// index lists the checkpoints found so far, sorted by offset.
+type index struct {
+	mu  sync.Mutex
+	cps []checkpoint
+}
+
+func (x *index) add(cp checkpoint) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	i := sort.Search(len(x.cps), func(i int) bool { return x.cps[i].pos >= cp.pos })
+	if i < len(x.cps) && x.cps[i].pos == cp.pos {
+		return
+	}
+	x.cps = append(x.cps, checkpoint{})
+	copy(x.cps[i+1:], x.cps[i:])
+	x.cps[i] = cp
+}
+
+// find returns the last checkpoint at or before pos.
+func (x *index) find(pos int64) checkpoint {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	i := sort.Search(len(x.cps), func(i int) bool { return x.cps[i].pos > pos })
+	return x.cps[i-1]
+}
+
+// last returns the last checkpoint.
+func (x *index) last() checkpoint {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.cps[len(x.cps)-1]
+}
+
+// A cursor decompresses a gzip file one member at a time, so that it knows
+// where each member starts, and adds these places to an index.
+type cursor struct {
+	idx *index
+	in  iocount.Reader
+	br  *bufio.Reader
+	z   *Reader
+	pos int64 // offset of the next byte z returns
+	ok  bool  // z was started without error
+}
+
+// start starts decompressing the member at offset off of src, whose first
+// byte is at pos in the decompressed file.
+func (c *cursor) start(src io.Reader, off, pos int64) error {
+	c.in = iocount.Reader{R: src, N: off}
+	if c.br == nil {
+		c.br = bufio.NewReader(&c.in)
+	} else {
+		c.br.Reset(&c.in)
+	}
+	var err error
+	if c.z == nil {
+		c.z, err = NewReader(c.br)
+	} else {
+		err = c.z.Reset(c.br)
+	}
+	if c.ok = err == nil; !c.ok {
+		return err
+	}
+	c.z.Multistream(false)
+	c.pos = pos
+	return nil
+}
+
+func (c *cursor) Read(p []byte) (int, error) {
+	for {
+		n, err := c.z.Read(p)
+		c.pos += int64(n)
+		if err != io.EOF {
+			return n, err
+		}
+		// At the end of a member. Reset reads the header of the next one,
+		// or returns io.EOF at the end of the file.
+		off := c.in.N - int64(c.br.Buffered())
+		if err = c.z.Reset(c.br); err != nil {
+			return n, err
+		}
+		c.z.Multistream(false)
+		c.idx.add(checkpoint{off: off, pos: c.pos})
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// end moves c to the end of the file from the last checkpoint, adding the
+// start of every member on the way to the index, and returns the size of
+// the file.
+func (c *cursor) end(src io.ReadSeeker) (int64, error) {
+	if err := c.seek(src, c.idx.last().pos); err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(io.Discard, c); err != nil {
+		return 0, err
+	}
+	return c.pos, nil
+}
+
+// seek moves c to offset pos, from the closest checkpoint before it unless
+// c is between them already.
+func (c *cursor) seek(src io.ReadSeeker, pos int64) error {
+	cp := c.idx.find(pos)
+	if !c.ok || pos < c.pos || cp.pos > c.pos {
+		if _, err := src.Seek(cp.off, io.SeekStart); err != nil {
+			return err
+		}
+		if err := c.start(src, cp.off, cp.pos); err != nil {
+			return err
+		}
+	}
+	_, err := io.CopyN(io.Discard, c, pos-c.pos)
+	return err
+}
+
+// file is an open decompressed file.
+type file struct {
+	fsys *gzFS
+	name string
+	gz   fs.File // the gzip file
+	rd   cursor  // for Read
+	off  int64   // offset of the next Read, set by Seek
+	info fs.FileInfo
+	idx  index // shared by rd and ra
+
+	mu    sync.Mutex        // guards ra and raSrc
+	ra    cursor            // for ReadAt, independent of Read
+	raSrc *io.SectionReader // the gzip file, read through ReadAt
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	if f.info == nil {
+		gzInfo, err := f.gz.Stat()
+		if err != nil {
+			return nil, err
+		}
+		size, err := f.size()
+		if err != nil {
+			return nil, &fs.PathError{Op: "stat", Path: f.name, Err: err}
+		}
+		f.info = newFileInfo(gzInfo, size)
+	}
+	return f.info, nil
+}
+
+// size returns the size of the decompressed file. It decompresses the gzip
+// file with the cursor of ReadAt or Read if it can, so that the members
+// found go to the index, and opens it again otherwise.
+func (f *file) size() (int64, error) {
+	if _, ok := f.gz.(io.ReaderAt); ok {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		src, err := f.readAtSource()
+		if err != nil {
+			return 0, err
+		}
+		return f.ra.end(src)
+	}
+	if s, ok := f.gz.(io.ReadSeeker); ok {
+		// The next Read seeks back to f.off.
+		return f.rd.end(s)
+	}
+	return uncompressedSize(f.fsys.fsys, f.name+".gz")
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.off != f.rd.pos {
+		var err error
+		if s, ok := f.gz.(io.ReadSeeker); ok {
+			err = f.rd.seek(s, f.off)
+		} else if f.off > f.rd.pos {
+			_, err = io.CopyN(io.Discard, &f.rd, f.off-f.rd.pos)
+		} else {
+			err = &fs.PathError{Op: "read", Path: f.name, Err: errNotSeekable}
+		}
+		f.off = f.rd.pos
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.rd.Read(p)
+	f.off = f.rd.pos
+	return n, err
+}
+
+// seek sets the offset of the next Read, which moves there.
+func (f *file) seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		info, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		offset += info.Size()
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.off = offset
+	return offset, nil
+}
+
+// readAt reads len(p) bytes at offset off, decompressing with a cursor of
+// its own so that the offset of Read does not change.
+func (f *file) readAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	src, err := f.readAtSource()
+	if err != nil {
+		return 0, err
+	}
+	if err = f.ra.seek(src, off); err != nil {
+		return 0, err
+	}
+	for err == nil && n < len(p) {
+		var m int
+		m, err = f.ra.Read(p[n:])
+		n += m
+	}
+	if n == len(p) {
+		err = nil
+	}
+	return n, err
+}
+
+// readAtSource returns the gzip file as read by the cursor of ReadAt,
+// setting them up on first use. It is called with mu held.
+func (f *file) readAtSource() (*io.SectionReader, error) {
+	if f.raSrc == nil {
+		gzInfo, err := f.gz.Stat()
+		if err != nil {
+			return nil, err
+		}
+		f.raSrc = io.NewSectionReader(f.gz.(io.ReaderAt), 0, gzInfo.Size())
+		f.ra.idx = &f.idx
+	}
+	return f.raSrc, nil
+}
+
+func (f *file) Close() error {
+	err := f.rd.z.Close()
+	if cerr := f.gz.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// seekerFile, readerAtFile and seekReaderAtFile are open decompressed
+// files with the Seek and ReadAt methods their gzip file allows.
+type seekerFile struct{ *file }
+
+func (f seekerFile) Seek(offset int64, whence int) (int64, error) { return f.seek(offset, whence) }
+
+type readerAtFile struct{ *file }
+
+func (f readerAtFile) ReadAt(p []byte, off int64) (int, error) { return f.readAt(p, off) }
+
+type seekReaderAtFile struct{ *file }
+
+func (f seekReaderAtFile) Seek(offset int64, whence int) (int64, error) {
+	return f.seek(offset, whence)
+}
+
+func (f seekReaderAtFile) ReadAt(p []byte, off int64) (int, error) { return f.readAt(p, off) }
@@ -0,0 +1,296 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func gzipped(data string) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write([]byte(data))
+	w.Close()
+	return buf.Bytes()
+}
+
+func testFS() fstest.MapFS {
+	big := strings.Repeat("a line of a big configuration file\n", 10000)
+	return fstest.MapFS{
+		"plain.txt":           {Data: []byte("not compressed")},
+		"big.conf.gz":         {Data: gzipped(big)},
+		"dir/small.json.gz":   {Data: gzipped(`{"a": 1}`)},
+		"dir/empty.gz":        {Data: gzipped("")},
+		"both.txt":            {Data: []byte("the plain one wins")},
+		"both.txt.gz":         {Data: gzipped("hidden")},
+		"dir/sub/nested.gz":   {Data: gzipped(big[:5000])},
+		"dir/sub/notgzip.txt": {Data: []byte("x")},
+	}
+}
+
+func TestFS(t *testing.T) {
+	fsys := NewFS(testFS())
+	if err := fstest.TestFS(fsys, "plain.txt", "big.conf", "dir/small.json", "dir/empty",
+		"both.txt", "both.txt.gz", "dir/sub/nested", "dir/sub/notgzip.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"big.conf":       strings.Repeat("a line of a big configuration file\n", 10000),
+		"dir/small.json": `{"a": 1}`,
+		"both.txt":       "the plain one wins",
+	} {
+		got, err := fs.ReadFile(fsys, name)
+		if err != nil || string(got) != want {
+			t.Fatalf("%s: got %d bytes, %v", name, len(got), err)
+		}
+		info, err := fs.Stat(fsys, name)
+		if err != nil || info.Size() != int64(len(want)) || info.Name() != name[strings.LastIndex(name, "/")+1:] {
+			t.Fatalf("%s: Stat = %v, %v", name, info, err)
+		}
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got, want := strings.Join(names, " "), "big.conf both.txt both.txt.gz dir plain.txt"; got != want {
+		t.Fatalf("ReadDir = %q, want %q", got, want)
+	}
+	if _, err := fsys.Open("missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Open(missing.txt) = %v", err)
+	}
+}
+
+func TestFSReadAt(t *testing.T) {
+	fsys := NewFS(testFS())
+	f, err := fsys.Open("big.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	want, _ := fs.ReadFile(fsys, "big.conf")
+	ra := f.(io.ReaderAt)
+	buf := make([]byte, 1000)
+	for _, off := range []int64{200000, 0, 5, 100000, 100000, 349000, int64(len(want)) - 1000} {
+		n, err := ra.ReadAt(buf, off)
+		if err != nil || !bytes.Equal(buf[:n], want[off:off+1000]) {
+			t.Fatalf("ReadAt(%d) = %d, %v", off, n, err)
+		}
+	}
+	if n, err := ra.ReadAt(buf, int64(len(want))-10); n != 10 || err != io.EOF {
+		t.Fatalf("ReadAt at the end = %d, %v; want 10, EOF", n, err)
+	}
+	// ReadAt does not move the offset of Read.
+	if n, err := io.ReadFull(f, buf); err != nil || !bytes.Equal(buf[:n], want[:1000]) {
+		t.Fatalf("Read after ReadAt = %d, %v", n, err)
+	}
+}
+
+// countFile counts the bytes read from a file through ReadAt and Read.
+type countFile struct {
+	fs.File
+	n *int64
+}
+
+func (f countFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	*f.n += int64(n)
+	return n, err
+}
+
+func (f countFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.(io.ReaderAt).ReadAt(p, off)
+	*f.n += int64(n)
+	return n, err
+}
+
+func (f countFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+type countFS struct {
+	fs.FS
+	n *int64
+}
+
+func (c countFS) Open(name string) (fs.File, error) {
+	f, err := c.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return countFile{f, c.n}, nil
+}
+
+func TestFSMembers(t *testing.T) {
+	const members, size = 64, 8 << 10
+	rnd := rand.New(rand.NewSource(1))
+	want := make([]byte, members*size)
+	rnd.Read(want)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i := 0; i < members; i++ {
+		if i > 0 {
+			w.NewMember(Header{})
+		}
+		w.Write(want[i*size : (i+1)*size])
+	}
+	w.Close()
+
+	var read int64
+	fsys := NewFS(countFS{fstest.MapFS{"m.bin.gz": {Data: buf.Bytes()}}, &read})
+	f, err := fsys.Open("m.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if got, err := io.ReadAll(f); err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("ReadAll = %d bytes, %v", len(got), err)
+	}
+
+	// Once the members have been reached, going back to one of them only
+	// decompresses that member.
+	p := make([]byte, 100)
+	for _, off := range []int64{60*size + 5, 10 * size, 30*size + size - 50, 2} {
+		read = 0
+		if n, err := f.(io.ReaderAt).ReadAt(p, off); err != nil || !bytes.Equal(p[:n], want[off:off+100]) {
+			t.Fatalf("ReadAt(%d) = %d, %v", off, n, err)
+		}
+		if read > 2*size {
+			t.Fatalf("ReadAt(%d) read %d bytes of %d", off, read, buf.Len())
+		}
+		read = 0
+		if _, err := f.(io.Seeker).Seek(off, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := io.ReadFull(f, p); err != nil || !bytes.Equal(p[:n], want[off:off+100]) {
+			t.Fatalf("Read at %d = %d, %v", off, n, err)
+		}
+		if read > 2*size {
+			t.Fatalf("Read at %d read %d bytes of %d", off, read, buf.Len())
+		}
+	}
+
+	// The size counts every member, not just the ISIZE of the last one.
+	if info, err := fs.Stat(fsys, "m.bin"); err != nil || info.Size() != int64(len(want)) {
+		t.Fatalf("Stat = %v, %v", info, err)
+	}
+	f, err = fsys.Open("m.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if end, err := f.(io.Seeker).Seek(0, io.SeekEnd); err != nil || end != int64(len(want)) {
+		t.Fatalf("Seek(0, io.SeekEnd) = %d, %v", end, err)
+	}
+	// Finding the size indexed the members.
+	read = 0
+	off := int64(50*size + 7)
+	if n, err := f.(io.ReaderAt).ReadAt(p, off); err != nil || !bytes.Equal(p[:n], want[off:off+100]) {
+		t.Fatalf("ReadAt(%d) = %d, %v", off, n, err)
+	}
+	if read > 2*size {
+		t.Fatalf("ReadAt(%d) after Seek read %d bytes of %d", off, read, buf.Len())
+	}
+	if n, err := f.Read(p); n != 0 || err != io.EOF {
+		t.Fatalf("Read at the end = %d, %v", n, err)
+	}
+	if _, err := f.(io.Seeker).Seek(off, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := io.ReadFull(f, p); err != nil || !bytes.Equal(p[:n], want[off:off+100]) {
+		t.Fatalf("Read at %d after Seek = %d, %v", off, n, err)
+	}
+}
+
+// streamFile hides the Seek and ReadAt methods of a file.
+type streamFile struct{ fs.File }
+
+type streamFS struct{ fs.FS }
+
+func (s streamFS) Open(name string) (fs.File, error) {
+	f, err := s.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return streamFile{f}, nil
+}
+
+func TestFSNotSeekable(t *testing.T) {
+	fsys := NewFS(streamFS{testFS()})
+	f, err := fsys.Open("dir/small.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.Size() != 8 {
+		t.Fatalf("Stat = %v, %v", info, err)
+	}
+	if got, err := io.ReadAll(f); err != nil || string(got) != `{"a": 1}` {
+		t.Fatalf("ReadAll = %q, %v", got, err)
+	}
+	if _, ok := f.(io.ReaderAt); ok {
+		t.Fatal("file of a stream implements io.ReaderAt")
+	}
+	if _, ok := f.(io.Seeker); ok {
+		t.Fatal("file of a stream implements io.Seeker")
+	}
+}
+
+// seekOnlyFile hides the ReadAt method of a file.
+type seekOnlyFile struct{ fs.File }
+
+func (f seekOnlyFile) Seek(offset int64, whence int) (int64, error) {
+	return f.File.(io.Seeker).Seek(offset, whence)
+}
+
+type seekOnlyFS struct{ fs.FS }
+
+func (s seekOnlyFS) Open(name string) (fs.File, error) {
+	f, err := s.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return seekOnlyFile{f}, nil
+}
+
+// TestFSServeContent checks that http.ServeContent, which looks for
+// io.Seeker, serves ranges of a file whose gzip file can seek.
+func TestFSServeContent(t *testing.T) {
+	fsys := NewFS(seekOnlyFS{testFS()})
+	f, err := fsys.Open("dir/small.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, ok := f.(io.ReaderAt); ok {
+		t.Fatal("file implements io.ReaderAt, its gzip file does not")
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		t.Fatal("file does not implement io.Seeker, its gzip file does")
+	}
+	req := httptest.NewRequest("GET", "/small.json", nil)
+	req.Header.Set("Range", "bytes=1-4")
+	rec := httptest.NewRecorder()
+	http.ServeContent(rec, req, "small.json", time.Time{}, rs)
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != `"a":` {
+		t.Fatalf("ServeContent = %d %q", rec.Code, rec.Body.String())
+	}
+}
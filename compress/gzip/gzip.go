@@ -11,6 +11,7 @@ package gzip
 import (
 	"errors"
 	"fmt"
+	"hash"
 	"hash/crc32"
 	"io"
 	"time"
@@ -37,11 +38,12 @@ type Writer struct {
 	level       int           // Compression level
 	wroteHeader bool          // Whether header has been written
 	compressor  *flate.Writer // Intel-optimized DEFLATE compressor
-	digest      uint32        // CRC-32 checksum, IEEE polynomial (section 8)
+	digest      hash.Hash32   // CRC-32 checksum, IEEE polynomial (section 8), fed by the compressor
 	size        uint32        // Uncompressed size (section 2.3.1)
 	closed      bool          // Whether writer has been closed
 	buf         [10]byte      // Temporary buffer for header/footer
 	err         error         // Last error encountered
+	rsyncable   bool          // Whether rsyncable output is requested
 }
 
 // NewWriter creates a new Intel-optimized gzip Writer.
@@ -77,6 +79,8 @@ func (z *Writer) init(w io.Writer, level int) {
 	compressor := z.compressor
 	if compressor != nil {
 		compressor.Reset(w)
+		compressor.SetLevel(level) // checked by NewWriterLevel or SetLevel
+		z.digest.Reset()
 	}
 	*z = Writer{
 		Header: Header{
@@ -85,6 +89,8 @@ func (z *Writer) init(w io.Writer, level int) {
 		w:          w,
 		level:      level,
 		compressor: compressor,
+		digest:     z.digest,
+		rsyncable:  z.rsyncable,
 	}
 }
 
@@ -96,6 +102,86 @@ func (z *Writer) Reset(w io.Writer) {
 	z.init(w, z.level)
 }
 
+// Rsyncable controls whether the Writer produces rsyncable output, like
+// `gzip --rsyncable`. See flate.Writer.Rsyncable for how sync points are
+// chosen and for the cost in compression ratio.
+//
+// It returns an error if the Writer's level is not HuffmanOnly, BestSpeed,
+// 2 or DefaultCompression. The setting survives Reset and NewMember.
+func (z *Writer) Rsyncable(ok bool) error {
+	if z.compressor != nil {
+		if err := z.compressor.Rsyncable(ok); err != nil {
+			return err
+		}
+	} else if ok && !rsyncableLevel(z.level) {
+		return fmt.Errorf("gzip: rsyncable mode is not supported at level %d", z.level)
+	}
+	z.rsyncable = ok
+	return nil
+}
+
+// rsyncableLevel reports whether rsyncable mode is supported at level.
+func rsyncableLevel(level int) bool {
+	return level == HuffmanOnly || level == DefaultCompression || level == BestSpeed || level == 2
+}
+
+// SetLevel changes the compression level of the data written from now on.
+// Within a member, the compressor switches without ending the member, as
+// described for flate.Writer.SetLevel. The level stays in effect after
+// Reset and NewMember. In rsyncable mode, it returns an error for the
+// levels that Rsyncable does not support.
+func (z *Writer) SetLevel(level int) error {
+	if level < HuffmanOnly || level > BestCompression {
+		return fmt.Errorf("gzip: invalid compression level: %d", level)
+	}
+	if z.err != nil {
+		return z.err
+	}
+	if z.compressor == nil || z.closed {
+		// The compressor takes the level when it is made, or reset by
+		// NewMember or Reset.
+		if z.rsyncable && !rsyncableLevel(level) {
+			return fmt.Errorf("gzip: rsyncable mode is not supported at level %d", level)
+		}
+	} else if err := z.compressor.SetLevel(level); err != nil {
+		return err
+	}
+	z.level = level
+	return nil
+}
+
+// NewMember finishes the current gzip member by writing its trailer and
+// starts a new member described by hdr on the same underlying writer.
+// The compressor is reused through Reset, so no new buffers are allocated.
+//
+// The result is a multi-member gzip file which Reader decodes in multistream
+// mode; each member can also be decoded on its own. If nothing has been
+// written since the Writer was created, reset or given its last member,
+// no empty member is emitted and only the header is replaced.
+//
+// Unlike NewWriter, NewMember does not set hdr.OS; callers that want
+// "unknown" should set it to 255.
+func (z *Writer) NewMember(hdr Header) error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.wroteHeader && !z.closed {
+		if err := z.Close(); err != nil {
+			return err
+		}
+	}
+	if z.closed && z.compressor != nil {
+		z.compressor.Reset(z.w)
+		z.compressor.SetLevel(z.level) // checked by SetLevel
+		z.digest.Reset()
+	}
+	z.Header = hdr
+	z.wroteHeader = false
+	z.closed = false
+	z.size = 0
+	return nil
+}
+
 // writeBytes writes a length-prefixed byte slice to z.w.
 func (z *Writer) writeBytes(b []byte) error {
 	if len(b) > 0xffff {
@@ -141,6 +227,50 @@ func (z *Writer) writeString(s string) (err error) {
 	return err
 }
 
+// writeHeader writes the gzip header described by z.Header to z.w.
+func (z *Writer) writeHeader() error {
+	z.buf = [10]byte{0: gzipID1, 1: gzipID2, 2: gzipDeflate}
+	if z.Extra != nil {
+		z.buf[3] |= 0x04
+	}
+	if z.Name != "" {
+		z.buf[3] |= 0x08
+	}
+	if z.Comment != "" {
+		z.buf[3] |= 0x10
+	}
+	if z.ModTime.After(time.Unix(0, 0)) {
+		// Section 2.3.1, the zero value for MTIME means that the
+		// modified time is not set.
+		le.PutUint32(z.buf[4:8], uint32(z.ModTime.Unix()))
+	}
+	if z.level == BestCompression {
+		z.buf[8] = 2
+	} else if z.level == BestSpeed {
+		z.buf[8] = 4
+	}
+	z.buf[9] = z.OS
+	if _, err := z.w.Write(z.buf[:10]); err != nil {
+		return err
+	}
+	if z.Extra != nil {
+		if err := z.writeBytes(z.Extra); err != nil {
+			return err
+		}
+	}
+	if z.Name != "" {
+		if err := z.writeString(z.Name); err != nil {
+			return err
+		}
+	}
+	if z.Comment != "" {
+		if err := z.writeString(z.Comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Write writes a compressed form of p to the underlying io.Writer. The
 // compressed bytes are not necessarily flushed until the Writer is closed.
 func (z *Writer) Write(p []byte) (int, error) {
@@ -151,55 +281,19 @@ func (z *Writer) Write(p []byte) (int, error) {
 	// Write the GZIP header lazily.
 	if !z.wroteHeader {
 		z.wroteHeader = true
-		z.buf = [10]byte{0: gzipID1, 1: gzipID2, 2: gzipDeflate}
-		if z.Extra != nil {
-			z.buf[3] |= 0x04
-		}
-		if z.Name != "" {
-			z.buf[3] |= 0x08
-		}
-		if z.Comment != "" {
-			z.buf[3] |= 0x10
-		}
-		if z.ModTime.After(time.Unix(0, 0)) {
-			// Section 2.3.1, the zero value for MTIME means that the
-			// modified time is not set.
-			le.PutUint32(z.buf[4:8], uint32(z.ModTime.Unix()))
-		}
-		if z.level == BestCompression {
-			z.buf[8] = 2
-		} else if z.level == BestSpeed {
-			z.buf[8] = 4
-		}
-		z.buf[9] = z.OS
-		_, z.err = z.w.Write(z.buf[:10])
-		if z.err != nil {
+		if z.err = z.writeHeader(); z.err != nil {
 			return 0, z.err
 		}
-		if z.Extra != nil {
-			z.err = z.writeBytes(z.Extra)
-			if z.err != nil {
-				return 0, z.err
-			}
-		}
-		if z.Name != "" {
-			z.err = z.writeString(z.Name)
-			if z.err != nil {
-				return 0, z.err
-			}
-		}
-		if z.Comment != "" {
-			z.err = z.writeString(z.Comment)
-			if z.err != nil {
-				return 0, z.err
-			}
-		}
 		if z.compressor == nil {
 			z.compressor, _ = flate.NewWriter(z.w, z.level)
+			z.digest = crc32.NewIEEE()
+			z.compressor.SetChecksum(z.digest)
+			if z.rsyncable {
+				z.compressor.Rsyncable(true)
+			}
 		}
 	}
 	z.size += uint32(len(p))
-	z.digest = crc32.Update(z.digest, crc32.IEEETable, p)
 	n, z.err = z.compressor.Write(p)
 	return n, z.err
 }
@@ -250,7 +344,7 @@ func (z *Writer) Close() error {
 	if z.err != nil {
 		return z.err
 	}
-	le.PutUint32(z.buf[:4], z.digest)
+	le.PutUint32(z.buf[:4], z.digest.Sum32())
 	le.PutUint32(z.buf[4:8], z.size)
 	_, z.err = z.w.Write(z.buf[:8])
 	return z.err
@@ -273,3 +273,209 @@ func TestLimitedWrite(t *testing.T) {
 		}
 	}
 }
+
+// TestWriterNewMember tests that NewMember produces a multi-member file
+// whose members decode both together and individually.
+func TestWriterNewMember(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriter(buf)
+	w.Name = "first"
+	if _, err := w.Write([]byte("hello ")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.NewMember(Header{Name: "second", OS: 255}); err != nil {
+		t.Fatalf("NewMember: %v", err)
+	}
+	if _, err := w.Write([]byte("world\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := w.NewMember(Header{Name: "third", OS: 255}); err != nil {
+		t.Fatalf("NewMember after Close: %v", err)
+	}
+	if err := w.NewMember(Header{Name: "fourth", OS: 255}); err != nil {
+		t.Fatalf("NewMember without data: %v", err)
+	}
+	if _, err := w.Write([]byte("bye\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if string(data) != "hello world\nbye\n" || err != nil {
+		t.Fatalf("ReadAll = %q, %v, want %q, nil", data, err, "hello world\nbye\n")
+	}
+
+	want := []string{"first", "second", "fourth"}
+	br := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	var names []string
+	for {
+		if err := r.Reset(br); err != nil {
+			if err != io.EOF {
+				t.Fatalf("Reset: %v", err)
+			}
+			break
+		}
+		r.Multistream(false)
+		if _, err := io.ReadAll(r); err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+		names = append(names, r.Name)
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("member names = %q, want %q", names, want)
+	}
+}
+
+// TestWriterRsyncable tests that rsyncable output decodes and that the
+// mode is rejected for levels served by the standard library.
+func TestWriterRsyncable(t *testing.T) {
+	payload := bytes.Repeat([]byte("rsyncable gzip output\n"), 5000)
+	buf := new(bytes.Buffer)
+	w := NewWriter(buf)
+	if err := w.Rsyncable(true); err != nil {
+		t.Fatalf("Rsyncable: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := w.Write(payload); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		if err := w.NewMember(Header{OS: 255}); err != nil {
+			t.Fatalf("NewMember: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	r, err := NewReader(buf)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(data, append(payload, payload...)) {
+		t.Fatalf("round trip mismatch")
+	}
+
+	w, _ = NewWriterLevel(io.Discard, BestCompression)
+	if err := w.Rsyncable(true); err == nil {
+		t.Fatalf("Rsyncable at level %d: got nil error", BestCompression)
+	}
+}
+
+func TestWriterSetLevel(t *testing.T) {
+	payload := bytes.Repeat([]byte("gzip output at changing levels\n"), 5000)
+	buf := new(bytes.Buffer)
+	w, _ := NewWriterLevel(buf, BestCompression)
+	if err := w.SetLevel(BestSpeed); err != nil { // before the header
+		t.Fatalf("SetLevel: %v", err)
+	}
+	var want []byte
+	for _, level := range []int{HuffmanOnly, 6, 2, NoCompression, BestSpeed} {
+		if _, err := w.Write(payload); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		want = append(want, payload...)
+		if err := w.SetLevel(level); err != nil {
+			t.Fatalf("SetLevel(%d): %v", level, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := w.SetLevel(6); err != nil { // after Close
+		t.Fatalf("SetLevel after Close: %v", err)
+	}
+	if err := w.NewMember(Header{}); err != nil {
+		t.Fatalf("NewMember: %v", err)
+	}
+	w.Write(payload)
+	want = append(want, payload...)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	r, err := NewReader(buf)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Fatalf("round trip mismatch")
+	}
+
+	w, _ = NewWriterLevel(io.Discard, BestSpeed)
+	if err := w.Rsyncable(true); err != nil {
+		t.Fatalf("Rsyncable: %v", err)
+	}
+	if err := w.SetLevel(BestCompression); err == nil {
+		t.Fatalf("SetLevel(%d) in rsyncable mode: got nil error", BestCompression)
+	}
+	if err := w.SetLevel(10); err == nil {
+		t.Fatalf("SetLevel(10): got nil error")
+	}
+}
+
+// TestWriterSetLevelReset checks that SetLevel keeps the compressor of a
+// Writer that was reset or given a new member, and that the output is that
+// of a Writer made at the new level.
+func TestWriterSetLevelReset(t *testing.T) {
+	payload := bytes.Repeat([]byte("gzip output after a reset\n"), 5000)
+	compress := func(level int) []byte {
+		var buf bytes.Buffer
+		w, _ := NewWriterLevel(&buf, level)
+		w.Write(payload)
+		w.Close()
+		return buf.Bytes()
+	}
+	var buf bytes.Buffer
+	w, _ := NewWriterLevel(&buf, 6)
+	w.Write(payload)
+	w.Close()
+	c := w.compressor
+	for _, level := range []int{BestSpeed, HuffmanOnly, 6, BestCompression, 2} {
+		buf.Reset()
+		w.Reset(&buf)
+		if err := w.SetLevel(level); err != nil {
+			t.Fatalf("SetLevel(%d) after Reset: %v", level, err)
+		}
+		w.Write(payload)
+		w.Close()
+		if w.compressor != c {
+			t.Fatalf("SetLevel(%d) after Reset replaced the compressor", level)
+		}
+		if !bytes.Equal(buf.Bytes(), compress(level)) {
+			t.Fatalf("level %d after Reset: output differs from a new Writer", level)
+		}
+
+		buf.Reset()
+		w.Reset(&buf)
+		w.Write(payload)
+		w.Close()
+		if err := w.SetLevel(level); err != nil {
+			t.Fatalf("SetLevel(%d) after Close: %v", level, err)
+		}
+		w.NewMember(Header{OS: 255})
+		n := buf.Len()
+		w.Write(payload)
+		w.Close()
+		if w.compressor != c {
+			t.Fatalf("SetLevel(%d) before NewMember replaced the compressor", level)
+		}
+		if !bytes.Equal(buf.Bytes()[n:], compress(level)) {
+			t.Fatalf("level %d in a new member: output differs from a new Writer", level)
+		}
+	}
+}
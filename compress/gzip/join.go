@@ -0,0 +1,70 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/intel/fastgo/compress/flate"
+	"github.com/intel/fastgo/hash/crc32"
+)
+
+// Join writes to w a single-member gzip file whose data is the concatenation
+// of the data of the gzip files read from srcs, like zlib's gzjoin example.
+// Every member of every source is joined, in order. hdr is the header of the
+// result; as with NewMember, hdr.OS is written as given.
+//
+// The deflate data is not recompressed: it is spliced with flate.Splicer and
+// the trailer is computed from the trailers of the members, combining their
+// CRC-32s with crc32.Combine. The sizes of the members are checked against
+// their trailers, but their CRC-32s are not, as the decoded data is not
+// kept; use Verify first if the sources may be damaged.
+func Join(w io.Writer, hdr Header, srcs ...io.Reader) error {
+	z := Writer{Header: hdr, w: w}
+	if err := z.writeHeader(); err != nil {
+		return err
+	}
+	var (
+		s      = flate.NewSplicer(w)
+		digest uint32
+		size   uint32
+	)
+	for _, src := range srcs {
+		br, ok := src.(*bufio.Reader)
+		if !ok {
+			br = bufio.NewReader(src)
+		}
+		r := Reader{r: br}
+		for member := 0; ; member++ {
+			if member > 0 {
+				if _, err := br.Peek(1); err == io.EOF {
+					break
+				}
+			}
+			if _, err := r.readHeader(); err != nil {
+				return noEOF(err)
+			}
+			n, err := s.Add(br)
+			if err != nil {
+				return err
+			}
+			if _, err := io.ReadFull(br, r.buf[:8]); err != nil {
+				return noEOF(err)
+			}
+			if le.Uint32(r.buf[4:8]) != uint32(n) {
+				return ErrChecksum
+			}
+			digest = crc32.Combine(digest, le.Uint32(r.buf[:4]), n)
+			size += uint32(n)
+		}
+	}
+	if err := s.Close(); err != nil {
+		return err
+	}
+	le.PutUint32(z.buf[:4], digest)
+	le.PutUint32(z.buf[4:8], size)
+	_, err := w.Write(z.buf[:8])
+	return err
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestJoin(t *testing.T) {
+	var (
+		srcs []io.Reader
+		want []byte
+	)
+	for i := 0; i < 5; i++ {
+		line := []byte(time.Unix(int64(i)*3600, 0).UTC().Format(time.RFC3339) + " log line\n")
+		a := bytes.Repeat(line, 1000*i)
+		b := bytes.Repeat([]byte("second member\n"), i+1)
+		file, _ := gzipMembers(t, false, a, b)
+		if i == 2 {
+			file, _ = gzipMembers(t, false, a)
+			b = nil
+		}
+		srcs = append(srcs, bytes.NewReader(file))
+		want = append(append(want, a...), b...)
+	}
+
+	var out bytes.Buffer
+	hdr := Header{Name: "joined.log", OS: 3}
+	if err := Join(&out, hdr, srcs...); err != nil {
+		t.Fatalf("Join: %v", err)
+	}
+
+	z, err := NewReader(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z.Multistream(false)
+	got, err := io.ReadAll(z)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("first member: %d bytes, %v; want %d bytes", len(got), err, len(want))
+	}
+	if z.Name != hdr.Name || z.OS != hdr.OS {
+		t.Fatalf("header = %+v, want %+v", z.Header, hdr)
+	}
+	if err := z.Reset(bytes.NewReader(nil)); err != io.EOF {
+		t.Fatalf("Reset on nothing: %v", err)
+	}
+
+	sz, err := gzip.NewReader(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(sz); err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("std reader: %d bytes, %v; want %d bytes", len(got), err, len(want))
+	}
+}
+
+func TestJoinErrors(t *testing.T) {
+	file, _ := gzipMembers(t, false, []byte("hello, world\n"))
+	if err := Join(io.Discard, Header{}, bytes.NewReader(file), bytes.NewReader(nil)); err != io.ErrUnexpectedEOF {
+		t.Fatalf("empty source: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if err := Join(io.Discard, Header{}, bytes.NewReader(file[:len(file)-3])); err != io.ErrUnexpectedEOF {
+		t.Fatalf("truncated trailer: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	bad := append([]byte(nil), file...)
+	bad[len(bad)-4]++ // ISIZE
+	if err := Join(io.Discard, Header{}, bytes.NewReader(bad)); err != ErrChecksum {
+		t.Fatalf("wrong size: got %v, want %v", err, ErrChecksum)
+	}
+
+	var out bytes.Buffer
+	if err := Join(&out, Header{}); err != nil {
+		t.Fatal(err)
+	}
+	z, err := NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(z); err != nil || len(got) != 0 {
+		t.Fatalf("Join of nothing: %q, %v", got, err)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bytes"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/intel/fastgo/compress/flate"
+	"github.com/intel/fastgo/internal/iocount"
+)
+
+// A CompressingReader compresses the data of a source reader and returns
+// the gzip file from its Read method, without a goroutine. It wraps a
+// flate.CompressingReader, whose description applies, between the gzip
+// header and trailer. The Header field can be modified before the first
+// call to Read.
+type CompressingReader struct {
+	Header  // written at the first call to Read
+	level   int
+	in      iocount.Reader // the source, counted for the trailer
+	fr      *flate.CompressingReader
+	digest  hash.Hash32
+	started bool         // the header is in out
+	out     bytes.Buffer // header or trailer not read yet
+	err     error        // returned once out is drained
+}
+
+// NewCompressingReader returns a CompressingReader compressing the data
+// read from src at the given level, which is checked like by
+// NewWriterLevel.
+func NewCompressingReader(src io.Reader, level int) (*CompressingReader, error) {
+	if _, err := NewWriterLevel(nil, level); err != nil {
+		return nil, err
+	}
+	r := &CompressingReader{Header: Header{OS: 255}, level: level, in: iocount.Reader{R: src}}
+	r.fr, _ = flate.NewCompressingReader(&r.in, level)
+	r.digest = crc32.NewIEEE()
+	r.fr.SetChecksum(r.digest)
+	return r, nil
+}
+
+// Read reads compressed data. It returns io.EOF once the whole file,
+// trailer included, has been read. Errors of src are returned as is and
+// end the file.
+func (r *CompressingReader) Read(p []byte) (int, error) {
+	if !r.started {
+		r.started = true
+		z := Writer{Header: r.Header, w: &r.out, level: r.level}
+		if err := z.writeHeader(); err != nil {
+			r.out.Reset()
+			r.err = err
+		}
+	}
+	if r.out.Len() > 0 {
+		return r.out.Read(p)
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.fr.Read(p)
+	if err == io.EOF {
+		var trailer [8]byte
+		le.PutUint32(trailer[:4], r.digest.Sum32())
+		le.PutUint32(trailer[4:], uint32(r.in.N))
+		r.out.Write(trailer[:])
+		r.err = io.EOF
+		if n == 0 {
+			return r.out.Read(p)
+		}
+		err = nil
+	}
+	return n, err
+}
+
+// Reset discards the state of r and makes it compress the data read from
+// src, reusing its compressor. The Header is reset as well.
+func (r *CompressingReader) Reset(src io.Reader) {
+	r.Header = Header{OS: 255}
+	r.in = iocount.Reader{R: src}
+	r.fr.Reset(&r.in)
+	r.digest.Reset()
+	r.started = false
+	r.out.Reset()
+	r.err = nil
+}
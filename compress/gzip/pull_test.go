@@ -0,0 +1,69 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestCompressingReader(t *testing.T) {
+	data := []byte(strings.Repeat("a gzip file read from a reader\n", 20000))
+	for _, level := range []int{HuffmanOnly, BestSpeed, 2, DefaultCompression, BestCompression} {
+		r, err := NewCompressingReader(iotest.HalfReader(bytes.NewReader(data)), level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Name = "data.txt"
+		r.ModTime = time.Unix(1e9, 0)
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		got, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("level %d: round trip differs", level)
+		}
+		if gz.Name != "data.txt" || !gz.ModTime.Equal(r.ModTime) {
+			t.Fatalf("level %d: header %q %v", level, gz.Name, gz.ModTime)
+		}
+	}
+}
+
+func TestCompressingReaderReset(t *testing.T) {
+	errSrc := errors.New("source failed")
+	r, _ := NewCompressingReader(iotest.ErrReader(errSrc), BestSpeed)
+	r.Name = "first"
+	if _, err := io.ReadAll(r); err != errSrc {
+		t.Fatalf("got %v, want %v", err, errSrc)
+	}
+
+	r.Reset(strings.NewReader("payload"))
+	if r.Name != "" {
+		t.Fatalf("Reset kept header name %q", r.Name)
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(gz)
+	if err != nil || string(got) != "payload" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+}
+
+func TestCompressingReaderLevel(t *testing.T) {
+	if _, err := NewCompressingReader(nil, 10); err == nil {
+		t.Fatal("accepted level 10")
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bufio"
+	"bytes"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/intel/fastgo/compress/flate"
+)
+
+// Parts of a gzip member an Inflater can be in.
+const (
+	inHeader = iota
+	inBody
+	inTrailer
+)
+
+// An Inflater decompresses a gzip file written to it and writes the data to
+// a destination writer, without blocking for more input. It is the gzip
+// counterpart of flate.Inflater. Like a Reader, it takes a series of
+// members and checks their trailers.
+type Inflater struct {
+	Header      // of the first member, valid once its header was written
+	dst         io.Writer
+	fi          *flate.Inflater
+	sum         hash.Hash32 // CRC-32 of the member data, fed by fi
+	size        uint32      // Uncompressed size of the member
+	part        int         // inHeader, inBody or inTrailer
+	buf         []byte      // partial header or trailer
+	members     int         // members read
+	err         error
+	hr          Reader // parses headers from br
+	br          bytes.Reader
+	wroteHeader bool // whether the Header field was set
+}
+
+// NewInflater returns an Inflater writing the decompressed data to dst.
+func NewInflater(dst io.Writer) *Inflater {
+	z := &Inflater{}
+	z.Reset(dst)
+	return z
+}
+
+// Reset discards the state of z and makes it decompress a new file to dst.
+func (z *Inflater) Reset(dst io.Writer) {
+	*z = Inflater{
+		dst: dst,
+		fi:  z.fi,
+		sum: z.sum,
+		buf: z.buf[:0],
+		hr:  Reader{r: z.hr.r},
+	}
+	if z.hr.r == nil {
+		z.hr.r = bufio.NewReaderSize(&z.br, 16)
+	}
+}
+
+// Write decompresses p and writes the data that it completes to the
+// destination. It returns ErrHeader for data after a member that is not a
+// gzip header, and ErrChecksum for a member with an invalid trailer. These,
+// corrupt input and errors of the destination stop z.
+func (z *Inflater) Write(p []byte) (n int, err error) {
+	for n < len(p) && z.err == nil {
+		var m int
+		switch z.part {
+		case inHeader:
+			m, z.err = z.writeHeader(p[n:])
+		case inBody:
+			m, z.err = z.fi.Write(p[n:])
+			if z.err == flate.ErrTrailingData || z.err == nil && z.fi.Done() {
+				z.err = nil
+				z.part = inTrailer
+			}
+		case inTrailer:
+			m = z.writeTrailer(p[n:])
+		}
+		n += m
+	}
+	return n, z.err
+}
+
+// Close tells z that the input has ended. It returns io.ErrUnexpectedEOF if
+// the input ended within a member or had none. It does not close the
+// destination.
+func (z *Inflater) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.part == inBody {
+		// The stream was not complete, or Done would have moved on.
+		z.err = z.fi.Close()
+		if z.err == nil {
+			z.err = io.ErrUnexpectedEOF
+		}
+		return z.err
+	}
+	if z.part != inHeader || len(z.buf) > 0 || z.members == 0 {
+		z.err = io.ErrUnexpectedEOF
+	}
+	return z.err
+}
+
+// writeHeader gathers the header of a member from p and starts the member
+// once it is complete. It returns the number of bytes of p it used.
+func (z *Inflater) writeHeader(p []byte) (int, error) {
+	src := p
+	if len(z.buf) > 0 {
+		src = append(z.buf, p...)
+	}
+	z.br.Reset(src)
+	z.hr.r.Reset(&z.br)
+	hdr, err := z.hr.readHeader()
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		if len(z.buf) == 0 {
+			z.buf = append(z.buf, p...)
+		} else {
+			z.buf = src
+		}
+		return len(p), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	used := len(src) - z.br.Len() - z.hr.r.Buffered() - len(z.buf)
+	z.buf = z.buf[:0]
+	if !z.wroteHeader {
+		z.Header = hdr
+		z.wroteHeader = true
+	}
+	if z.sum == nil {
+		z.sum = crc32.NewIEEE()
+	} else {
+		z.sum.Reset()
+	}
+	if z.fi == nil {
+		z.fi = flate.NewInflater(inflaterOutput{z})
+	} else {
+		z.fi.Reset(inflaterOutput{z}, nil)
+	}
+	z.fi.SetChecksum(z.sum)
+	z.size = 0
+	z.part = inBody
+	return used, nil
+}
+
+// writeTrailer gathers the trailer of a member from p and checks it once it
+// is complete. It returns the number of bytes of p it used.
+func (z *Inflater) writeTrailer(p []byte) int {
+	n := 8 - len(z.buf)
+	if n > len(p) {
+		n = len(p)
+	}
+	z.buf = append(z.buf, p[:n]...)
+	if len(z.buf) < 8 {
+		return n
+	}
+	if le.Uint32(z.buf[:4]) != z.sum.Sum32() || le.Uint32(z.buf[4:8]) != z.size {
+		z.err = ErrChecksum
+	}
+	z.buf = z.buf[:0]
+	z.members++
+	z.part = inHeader
+	return n
+}
+
+// inflaterOutput passes the output of an Inflater's decompressor to its
+// destination, counting it.
+type inflaterOutput struct {
+	z *Inflater
+}
+
+func (o inflaterOutput) Write(p []byte) (int, error) {
+	n, err := o.z.dst.Write(p)
+	o.z.size += uint32(n)
+	return n, err
+}
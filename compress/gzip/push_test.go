@@ -0,0 +1,101 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pushPieces writes in to z in pieces of random size up to max, then
+// closes it.
+func pushPieces(z *Inflater, in []byte, max int, rnd *rand.Rand) error {
+	for len(in) > 0 {
+		n := 1 + rnd.Intn(max)
+		if n > len(in) {
+			n = len(in)
+		}
+		if _, err := z.Write(in[:n]); err != nil {
+			return err
+		}
+		in = in[n:]
+	}
+	return z.Close()
+}
+
+func TestInflater(t *testing.T) {
+	data := []byte(strings.Repeat("a gzip file written in pieces\n", 10000))
+	var in bytes.Buffer
+	w := gzip.NewWriter(&in)
+	w.Name = "first.txt"
+	w.Comment = "comment"
+	w.Extra = []byte("extra")
+	w.ModTime = time.Unix(1e9, 0)
+	w.Write(data)
+	w.Close()
+	// A second member with a header CRC.
+	in.Write([]byte{0x1f, 0x8b, 8, flagHdrCrc | flagName, 0, 0, 0, 0, 0, 255, 'x', 0})
+	in.Write([]byte{0, 0})
+	hdr := in.Bytes()[in.Len()-14:]
+	le.PutUint16(hdr[12:], uint16(crc32.ChecksumIEEE(hdr[:12])))
+	var member bytes.Buffer
+	fw, _ := NewWriterLevel(&member, BestSpeed)
+	fw.Write(data[:1000])
+	fw.Close()
+	in.Write(member.Bytes()[10:]) // past its plain header
+	want := append(data[:len(data):len(data)], data[:1000]...)
+
+	rnd := rand.New(rand.NewSource(1))
+	for _, max := range []int{1, 5, 300, 1 << 20} {
+		var out bytes.Buffer
+		z := NewInflater(&out)
+		if err := pushPieces(z, in.Bytes(), max, rnd); err != nil {
+			t.Fatalf("pieces of %d: %v", max, err)
+		}
+		if !bytes.Equal(out.Bytes(), want) {
+			t.Fatalf("pieces of %d: got %d bytes, want %d", max, out.Len(), len(want))
+		}
+		if z.Name != "first.txt" || z.Comment != "comment" || string(z.Extra) != "extra" || !z.ModTime.Equal(time.Unix(1e9, 0)) {
+			t.Fatalf("pieces of %d: header %+v", max, z.Header)
+		}
+	}
+}
+
+func TestInflaterErrors(t *testing.T) {
+	var in bytes.Buffer
+	w := NewWriter(&in)
+	w.Write([]byte("payload"))
+	w.Close()
+	file := in.Bytes()
+
+	tests := []struct {
+		name  string
+		input []byte
+		write error
+		close error
+	}{
+		{"empty", nil, nil, io.ErrUnexpectedEOF},
+		{"header", file[:5], nil, io.ErrUnexpectedEOF},
+		{"body", file[:15], nil, io.ErrUnexpectedEOF},
+		{"trailer", file[:len(file)-3], nil, io.ErrUnexpectedEOF},
+		{"garbage", append(file[:len(file):len(file)], "garbage..."...), ErrHeader, ErrHeader},
+		{"checksum", append(file[:len(file)-1:len(file)-1], file[len(file)-1]+1), ErrChecksum, ErrChecksum},
+	}
+	z := NewInflater(io.Discard)
+	for _, tt := range tests {
+		z.Reset(io.Discard)
+		if _, err := z.Write(tt.input); err != tt.write {
+			t.Fatalf("%s: Write = %v, want %v", tt.name, err, tt.write)
+		}
+		if err := z.Close(); err != tt.close {
+			t.Fatalf("%s: Close = %v, want %v", tt.name, err, tt.close)
+		}
+	}
+}
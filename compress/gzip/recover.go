@@ -0,0 +1,242 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/intel/fastgo/compress/flate"
+	"github.com/intel/fastgo/internal/iocount"
+)
+
+// recoveryBufferSize is the size of the read buffer used in recovery mode.
+// It bounds how far ahead a resynchronization candidate is test-decoded.
+const recoveryBufferSize = 64 * 1024
+
+// recoveryLookahead is the amount of input a candidate needs after it,
+// unless the input ends first, before it is test-decoded.
+const recoveryLookahead = 4 * 1024
+
+// recoveryHistory is the amount of output kept in recovery mode, for the
+// back-references of blocks resumed at a sync-flush marker.
+const recoveryHistory = 32 * 1024
+
+// syncMarker is the LEN/NLEN pair of the empty stored block emitted by a
+// deflate sync flush. A new block starts right after it on a byte boundary.
+var syncMarker = []byte{0x00, 0x00, 0xff, 0xff}
+
+// A Corruption describes a damaged region skipped by a Reader in recovery
+// mode. Offsets are counted in bytes from the start of the compressed input.
+type Corruption struct {
+	Offset int64 // where the damage was detected
+	Resume int64 // where decoding resumed, or the end of the input
+	Err    error // the error Read would have returned outside recovery mode
+}
+
+// recovery holds the state of a Reader in recovery mode.
+type recovery struct {
+	report   func(Corruption)
+	input    *iocount.Reader
+	partial  bool // current member was entered at a sync point
+	noHeader bool // no member header has been read yet
+
+	// Output returned last, up to twice recoveryHistory, and the part of
+	// it the blocks found by resync refer to, or nil.
+	history []byte
+	dict    []byte
+
+	// Scratch decoders used to test-decode resynchronization candidates.
+	window       bytes.Reader
+	windowBuf    *bufio.Reader
+	trial        *Reader
+	trialInflate io.ReadCloser
+}
+
+// NewRecoveryReader is like NewReader, but returns a Reader in recovery mode.
+//
+// In recovery mode, a damaged member does not end the stream. When Read meets
+// a checksum mismatch, an invalid header or corrupt or truncated deflate data,
+// it calls report with the offsets involved, then scans forward from the
+// point where the damage was detected for either the header of a following
+// member or a deflate sync-flush marker, and continues from the first one
+// that test-decodes cleanly. If no candidate is found, Read returns io.EOF
+// at the end of the input.
+//
+// Output resumed at a sync-flush marker is not covered by a checksum. The
+// blocks after the marker may refer back to data lost in the damaged
+// region; such references are resolved against the last 32 KB of output
+// returned before the damage, so the bytes they copy are likely wrong.
+//
+// Data returned before a corruption is reported should be treated as suspect.
+// The Reader may read ahead of the gzip data it consumes. Reset returns the
+// Reader to normal mode.
+func NewRecoveryReader(r io.Reader, report func(Corruption)) (*Reader, error) {
+	rec := &recovery{
+		report:   report,
+		input:    &iocount.Reader{R: r},
+		noHeader: true,
+	}
+	z := &Reader{
+		r:           bufio.NewReaderSize(rec.input, recoveryBufferSize),
+		multistream: true,
+		recovery:    rec,
+	}
+	if err := z.nextHeader(); err != nil {
+		if err = z.salvage(err); err != nil {
+			return nil, err
+		}
+	}
+	return z, nil
+}
+
+// isCorruption reports whether err is caused by damaged input.
+func isCorruption(err error) bool {
+	if _, ok := err.(flate.CorruptInputError); ok {
+		return true
+	}
+	return err == ErrChecksum || err == ErrHeader || err == io.ErrUnexpectedEOF
+}
+
+// offset returns the number of compressed bytes consumed so far.
+func (z *Reader) offset() int64 {
+	return z.recovery.input.N - int64(z.r.Buffered())
+}
+
+// salvage handles err in recovery mode. It returns nil once decoding can go
+// on, or the error to report to the caller.
+func (z *Reader) salvage(err error) error {
+	rec := z.recovery
+	for rec != nil && isCorruption(err) {
+		c := Corruption{Offset: z.offset(), Err: err}
+		atHeader, rerr := z.resync()
+		c.Resume = z.offset()
+		if rec.report != nil {
+			rec.report(c)
+		}
+		if rerr != nil {
+			return rerr
+		}
+		z.size = 0
+		if !atHeader {
+			rec.partial = true
+			return z.resetDecompressor(rec.dict)
+		}
+		rec.partial = false
+		if err = z.nextHeader(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// resync discards input up to the next member header or sync-flush point
+// that test-decodes cleanly. It reports whether a member header was found,
+// and returns io.EOF if the input ends first.
+func (z *Reader) resync() (atHeader bool, err error) {
+scan:
+	for {
+		window, err := z.r.Peek(recoveryBufferSize)
+		eof := err != nil
+		if eof && err != io.EOF && err != bufio.ErrBufferFull {
+			return false, err
+		}
+		for i := 0; i < len(window); i++ {
+			header := bytes.HasPrefix(window[i:], gzipMagic)
+			if !header && !bytes.HasPrefix(window[i:], syncMarker) {
+				continue
+			}
+			if !eof && len(window)-i < recoveryLookahead {
+				// Move the candidate to the front of the buffer
+				// so that enough data follows it.
+				if _, err = z.r.Discard(i); err != nil {
+					return false, err
+				}
+				continue scan
+			}
+			if header && z.testMember(window[i:]) {
+				_, err = z.r.Discard(i)
+				return true, err
+			}
+			if !header && z.testBlocks(window[i+len(syncMarker):]) {
+				_, err = z.r.Discard(i + len(syncMarker))
+				return false, err
+			}
+		}
+		if eof {
+			z.r.Discard(len(window))
+			return false, io.EOF
+		}
+		// Keep a possible partial marker at the end of the window.
+		if _, err = z.r.Discard(len(window) - len(syncMarker) + 1); err != nil {
+			return false, err
+		}
+	}
+}
+
+// testMember reports whether b starts with a gzip header followed by
+// deflate data that decodes without error as far as b goes.
+func (z *Reader) testMember(b []byte) bool {
+	rec := z.recovery
+	if rec.trial == nil {
+		rec.trial = new(Reader)
+	}
+	rec.window.Reset(b)
+	if rec.windowBuf == nil {
+		rec.windowBuf = bufio.NewReader(&rec.window)
+	} else {
+		rec.windowBuf.Reset(&rec.window)
+	}
+	if err := rec.trial.Reset(rec.windowBuf); err != nil {
+		return false
+	}
+	rec.trial.Multistream(false)
+	_, err := io.Copy(io.Discard, rec.trial)
+	return err == nil || err == io.ErrUnexpectedEOF
+}
+
+// testBlocks reports whether b starts with deflate blocks that decode
+// without error as far as b goes. Blocks after a sync flush usually refer
+// to earlier data, so if they fail on their own they are tried again with
+// the output returned so far as history, which is then kept in rec.dict.
+func (z *Reader) testBlocks(b []byte) bool {
+	rec := z.recovery
+	rec.dict = nil
+	if z.tryBlocks(b, nil) {
+		return true
+	}
+	dict := rec.history
+	if len(dict) > recoveryHistory {
+		dict = dict[len(dict)-recoveryHistory:]
+	}
+	if len(dict) == 0 || !z.tryBlocks(b, dict) {
+		return false
+	}
+	rec.dict = dict
+	return true
+}
+
+func (z *Reader) tryBlocks(b, dict []byte) bool {
+	rec := z.recovery
+	rec.window.Reset(b)
+	if rec.trialInflate == nil {
+		rec.trialInflate = flate.NewReader(&rec.window)
+	}
+	rec.trialInflate.(flate.Resetter).Reset(&rec.window, dict)
+	_, err := io.Copy(io.Discard, rec.trialInflate)
+	return err == nil || err == io.ErrUnexpectedEOF
+}
+
+// remember keeps the end of the output p for testBlocks.
+func (rec *recovery) remember(p []byte) {
+	if len(p) > recoveryHistory {
+		p = p[len(p)-recoveryHistory:]
+	}
+	if len(rec.history)+len(p) > 2*recoveryHistory {
+		keep := rec.history[len(rec.history)-recoveryHistory:]
+		rec.history = append(rec.history[:0], keep...)
+	}
+	rec.history = append(rec.history, p...)
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func recoveryPayload(tag string, n int) []byte {
+	var b bytes.Buffer
+	for i := 0; b.Len() < n; i++ {
+		fmt.Fprintf(&b, "%s line %d: %x\n", tag, i, i*i*7919)
+	}
+	return b.Bytes()
+}
+
+// gzipMembers compresses each payload into its own member and returns the
+// file together with the offset at which each member starts.
+func gzipMembers(t *testing.T, rsyncable bool, payloads ...[]byte) ([]byte, []int) {
+	var buf bytes.Buffer
+	var starts []int
+	w := NewWriter(&buf)
+	if err := w.Rsyncable(rsyncable); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range payloads {
+		starts = append(starts, buf.Len())
+		w.Reset(&buf)
+		w.Write(p)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes(), starts
+}
+
+func readRecovering(t *testing.T, data []byte) ([]byte, []Corruption) {
+	var reports []Corruption
+	r, err := NewRecoveryReader(bytes.NewReader(data), func(c Corruption) {
+		reports = append(reports, c)
+	})
+	if err != nil {
+		t.Fatalf("NewRecoveryReader: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	return got, reports
+}
+
+func TestRecoverCorruptMember(t *testing.T) {
+	a := recoveryPayload("first", 50000)
+	b := recoveryPayload("second", 50000)
+	c := recoveryPayload("third", 50000)
+	data, starts := gzipMembers(t, false, a, b, c)
+	for i := starts[1] + 100; i < starts[1]+200; i++ {
+		data[i] ^= 0x55
+	}
+
+	if _, err := io.ReadAll(mustReader(t, data)); err == nil {
+		t.Fatal("normal mode: expected an error")
+	}
+
+	got, reports := readRecovering(t, data)
+	if !bytes.HasPrefix(got, a) || !bytes.HasSuffix(got, c) {
+		t.Fatalf("recovered %d bytes, want first and third members intact", len(got))
+	}
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1: %+v", len(reports), reports)
+	}
+	rep := reports[0]
+	if rep.Offset < int64(starts[1]) || rep.Offset > int64(starts[2]) {
+		t.Errorf("Offset = %d, want within second member [%d, %d]", rep.Offset, starts[1], starts[2])
+	}
+	if rep.Resume != int64(starts[2]) {
+		t.Errorf("Resume = %d, want %d", rep.Resume, starts[2])
+	}
+}
+
+func TestRecoverChecksum(t *testing.T) {
+	a := recoveryPayload("first", 1000)
+	b := recoveryPayload("second", 1000)
+	data, starts := gzipMembers(t, false, a, b)
+	data[starts[1]-8] ^= 0xff
+
+	got, reports := readRecovering(t, data)
+	if !bytes.Equal(got, append(append([]byte(nil), a...), b...)) {
+		t.Fatalf("recovered %d bytes, want %d", len(got), len(a)+len(b))
+	}
+	if len(reports) != 1 || reports[0].Err != ErrChecksum || reports[0].Resume != int64(starts[1]) {
+		t.Fatalf("reports = %+v, want one ErrChecksum resuming at %d", reports, starts[1])
+	}
+}
+
+func TestRecoverSyncPoint(t *testing.T) {
+	a := recoveryPayload("only", 200000)
+	data, _ := gzipMembers(t, true, a)
+	for i := 1000; i < 1100; i++ {
+		data[i] ^= 0x55
+	}
+
+	got, reports := readRecovering(t, data)
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1: %+v", len(reports), reports)
+	}
+	if len(got) < len(a)/2 || !bytes.HasSuffix(a, got[len(got)-len(a)/2:]) {
+		t.Fatalf("recovered %d of %d bytes, want the tail of the input", len(got), len(a))
+	}
+}
+
+func TestRecoverSyncFlush(t *testing.T) {
+	// A writer that keeps its history across sync flushes, so the blocks
+	// after each marker refer back to data before it.
+	a := recoveryPayload("flushed", 200000)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	var flushes []int
+	for i := 0; i < len(a); i += 10000 {
+		w.Write(a[i : i+10000])
+		w.Flush()
+		flushes = append(flushes, buf.Len())
+	}
+	w.Close()
+	data := buf.Bytes()
+	// Damage the block after a marker: 0xff bytes make an invalid block
+	// type.
+	for i := flushes[5]; i < flushes[5]+100; i++ {
+		data[i] = 0xff
+	}
+
+	got, reports := readRecovering(t, data)
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1: %+v", len(reports), reports)
+	}
+	if reports[0].Resume != int64(flushes[6]) {
+		t.Fatalf("Resume = %d, want the next sync point at %d", reports[0].Resume, flushes[6])
+	}
+	if len(got) < len(a)/2 {
+		t.Fatalf("recovered %d of %d bytes", len(got), len(a))
+	}
+}
+
+func TestRecoverTrailingGarbage(t *testing.T) {
+	a := recoveryPayload("first", 1000)
+	data, _ := gzipMembers(t, false, a)
+	size := len(data)
+	data = append(data, "not gzip data"...)
+
+	got, reports := readRecovering(t, data)
+	if !bytes.Equal(got, a) {
+		t.Fatalf("recovered %d bytes, want %d", len(got), len(a))
+	}
+	if len(reports) != 1 || reports[0].Err != ErrHeader || reports[0].Offset != int64(size) || reports[0].Resume != int64(len(data)) {
+		t.Fatalf("reports = %+v", reports)
+	}
+}
+
+func mustReader(t *testing.T, data []byte) *Reader {
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRecoverLeadingGarbage(t *testing.T) {
+	a := recoveryPayload("first", 1000)
+	var buf bytes.Buffer
+	buf.WriteString("junk")
+	w := NewWriter(&buf)
+	w.Name = "first.txt"
+	w.Write(a)
+	w.Close()
+
+	var reports []Corruption
+	r, err := NewRecoveryReader(&buf, func(c Corruption) { reports = append(reports, c) })
+	if err != nil {
+		t.Fatalf("NewRecoveryReader: %v", err)
+	}
+	if r.Name != "first.txt" {
+		t.Errorf("Name = %q, want %q", r.Name, "first.txt")
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, a) {
+		t.Fatalf("ReadAll = %d bytes, %v; want %d bytes", len(got), err, len(a))
+	}
+	if len(reports) != 1 || reports[0].Offset != 0 || reports[0].Resume != 4 {
+		t.Fatalf("reports = %+v", reports)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bufio"
+	"io"
+)
+
+// Info describes a gzip file, with the fields listed by `gzip -l`.
+type Info struct {
+	Header                 // header of the first member
+	CompressedSize   int64 // size of the gzip file in bytes
+	UncompressedSize int64 // size of the decompressed data in bytes
+	Members          int   // number of members, or 0 if not counted
+}
+
+// Stat reports the sizes and first header of the gzip file read from rs
+// without decompressing it, like `gzip -l`.
+//
+// By default only the first header and the trailer at the end of rs are read.
+// The uncompressed size then comes from the ISIZE field of the last member,
+// which is only correct for single-member files smaller than 4 GB, as ISIZE
+// holds the size modulo 2^32. If exact is true, every member is decompressed
+// and checked instead; UncompressedSize is then exact and Members is set.
+//
+// Stat reads rs from its start and leaves its offset unspecified.
+func Stat(rs io.ReadSeeker, exact bool) (*Info, error) {
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	info := &Info{CompressedSize: size}
+	z := Reader{r: bufio.NewReader(rs)}
+	if exact {
+		if err = statMembers(&z, info); err != nil {
+			return nil, err
+		}
+		return info, nil
+	}
+
+	if info.Header, err = z.readHeader(); err != nil {
+		return nil, noEOF(err)
+	}
+	if size < minMemberSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if _, err = rs.Seek(size-4, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err = io.ReadFull(rs, z.buf[:4]); err != nil {
+		return nil, noEOF(err)
+	}
+	info.UncompressedSize = int64(le.Uint32(z.buf[:4]))
+	return info, nil
+}
+
+// statMembers decompresses every member read by z and fills in info.
+func statMembers(z *Reader, info *Info) error {
+	br := z.r
+	for {
+		err := z.Reset(br)
+		if err == io.EOF && info.Members > 0 {
+			return nil
+		}
+		if err != nil {
+			return noEOF(err)
+		}
+		z.Multistream(false)
+		if info.Members == 0 {
+			info.Header = z.Header
+		}
+		n, err := io.Copy(io.Discard, z)
+		if err != nil {
+			return err
+		}
+		info.UncompressedSize += n
+		info.Members++
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestStat(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Name = "first.txt"
+	w.ModTime = time.Unix(1e8, 0)
+	first := bytes.Repeat([]byte("first member\n"), 1000)
+	second := bytes.Repeat([]byte("second\n"), 10)
+	w.Write(first)
+	w.NewMember(Header{Name: "second.txt", OS: 255})
+	w.Write(second)
+	w.Close()
+
+	info, err := Stat(bytes.NewReader(buf.Bytes()), false)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Name != "first.txt" || info.ModTime.Unix() != 1e8 {
+		t.Errorf("header = %+v", info.Header)
+	}
+	if info.CompressedSize != int64(buf.Len()) {
+		t.Errorf("CompressedSize = %d, want %d", info.CompressedSize, buf.Len())
+	}
+	// The quick mode only sees the trailer of the last member.
+	if info.UncompressedSize != int64(len(second)) || info.Members != 0 {
+		t.Errorf("UncompressedSize, Members = %d, %d; want %d, 0", info.UncompressedSize, info.Members, len(second))
+	}
+
+	info, err = Stat(bytes.NewReader(buf.Bytes()), true)
+	if err != nil {
+		t.Fatalf("Stat exact: %v", err)
+	}
+	if info.Name != "first.txt" {
+		t.Errorf("Name = %q, want %q", info.Name, "first.txt")
+	}
+	if want := int64(len(first) + len(second)); info.UncompressedSize != want || info.Members != 2 {
+		t.Errorf("UncompressedSize, Members = %d, %d; want %d, 2", info.UncompressedSize, info.Members, want)
+	}
+}
+
+func TestStatInvalid(t *testing.T) {
+	for _, input := range []string{"", "not a gzip file", "\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff"} {
+		for _, exact := range []bool{false, true} {
+			if info, err := Stat(bytes.NewReader([]byte(input)), exact); err == nil || info != nil {
+				t.Errorf("Stat(%q, %v): got %v, %v; want nil and an error", input, exact, info, err)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write([]byte("payload"))
+	w.Close()
+	data := buf.Bytes()
+	data[len(data)-8] ^= 0xff
+	if info, err := Stat(bytes.NewReader(data), true); err != ErrChecksum || info != nil {
+		t.Errorf("Stat exact on bad checksum: got %v, %v; want nil, %v", info, err, ErrChecksum)
+	}
+	if _, err := Stat(bytes.NewReader(data), false); err != nil {
+		t.Errorf("Stat on bad checksum: %v", err)
+	}
+}
@@ -10,8 +10,10 @@ package gzip
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"hash"
 	"hash/crc32"
 	"io"
 	"time"
@@ -82,11 +84,15 @@ type Reader struct {
 	Header       // valid after NewReader or Reader.Reset
 	r            *bufio.Reader
 	decompressor io.ReadCloser
-	digest       uint32 // CRC-32, IEEE polynomial (section 8)
-	size         uint32 // Uncompressed size (section 2.3.1)
+	digest       uint32      // CRC-32 of the header, IEEE polynomial (section 8)
+	sum          hash.Hash32 // CRC-32 of the member data, fed by the decompressor
+	size         uint32      // Uncompressed size (section 2.3.1)
 	buf          [512]byte
 	err          error
 	multistream  bool
+	recovery     *recovery // non-nil in recovery mode
+	allowTrail   bool      // stop cleanly at data that is not a gzip member
+	trailing     bool      // Read stopped at trailing data
 }
 
 // NewReader creates a new Reader reading the given reader.
@@ -110,6 +116,7 @@ func NewReader(r io.Reader) (*Reader, error) {
 func (z *Reader) Reset(r io.Reader) error {
 	*z = Reader{
 		decompressor: z.decompressor,
+		sum:          z.sum,
 		multistream:  true,
 	}
 
@@ -118,7 +125,7 @@ func (z *Reader) Reset(r io.Reader) error {
 	} else {
 		z.r = bufio.NewReader(r)
 	}
-	z.Header, z.err = z.readHeader()
+	z.Header, z.err = z.startMember()
 	return z.err
 }
 
@@ -142,6 +149,40 @@ func (z *Reader) Multistream(ok bool) {
 	z.multistream = ok
 }
 
+// IgnoreTrailingGarbage controls how a Reader in multistream mode handles
+// data after a member that does not start with a gzip header.
+//
+// By default such data makes Read fail with ErrHeader. When ok is true, Read
+// instead returns io.EOF at the end of the last valid member, in the manner of
+// GNU gzip's "decompression OK, trailing garbage ignored" warning, and the
+// unread data is available from Trailing. Data that starts with a gzip header
+// but is damaged is still reported as an error.
+//
+// Like Multistream, the setting is cleared by Reset.
+func (z *Reader) IgnoreTrailingGarbage(ok bool) {
+	z.allowTrail = ok
+}
+
+// Trailing returns a reader for the data following the last gzip member once
+// Read has returned io.EOF because of trailing garbage, and nil otherwise.
+// Callers can use it to warn about, inspect or consume that data.
+func (z *Reader) Trailing() io.Reader {
+	if !z.trailing {
+		return nil
+	}
+	return z.r
+}
+
+// atMember reports whether the unread input starts with the gzip magic,
+// without consuming it. It returns io.EOF if there is no input left.
+func (z *Reader) atMember() (bool, error) {
+	b, err := z.r.Peek(len(gzipMagic))
+	if len(b) == 0 && err != nil {
+		return false, err
+	}
+	return bytes.Equal(b, gzipMagic), nil
+}
+
 // readString reads a NUL-terminated string from z.r.
 // It treats the bytes read as being encoded as ISO 8859-1 (Latin-1) and
 // will output a string encoded using UTF-8.
@@ -178,6 +219,7 @@ func (z *Reader) readString() (string, error) {
 }
 
 // readHeader reads the GZIP header according to section 2.3.1.
+// It leaves z.r positioned at the start of the compressed data.
 // This method does not set z.err.
 func (z *Reader) readHeader() (hdr Header, err error) {
 	if _, err = io.ReadFull(z.r, z.buf[:10]); err != nil {
@@ -242,15 +284,58 @@ func (z *Reader) readHeader() (hdr Header, err error) {
 	}
 
 	z.digest = 0
+	return hdr, nil
+}
+
+// startMember reads the GZIP header of a member and prepares the
+// decompressor for the deflate data that follows it.
+// This method does not set z.err.
+func (z *Reader) startMember() (hdr Header, err error) {
+	if hdr, err = z.readHeader(); err != nil {
+		return hdr, err
+	}
+	return hdr, z.resetDecompressor(nil)
+}
+
+// resetDecompressor prepares the decompressor and the data checksum for
+// deflate data starting at the current position of z.r, with dict as the
+// history it may refer to.
+func (z *Reader) resetDecompressor(dict []byte) error {
+	if z.sum == nil {
+		z.sum = crc32.NewIEEE()
+	} else {
+		z.sum.Reset()
+	}
 	if z.decompressor == nil {
 		z.decompressor = flate.NewReader(z.r)
-	} else {
-		err = z.decompressor.(flate.Resetter).Reset(z.r, nil)
+		z.decompressor.(flate.Checksummer).SetChecksum(z.sum)
+		if dict == nil {
+			return nil
+		}
+	}
+	return z.decompressor.(flate.Resetter).Reset(z.r, dict)
+}
+
+// nextHeader reads the header of the next member. In recovery mode it does
+// not consume any input when the gzip magic is missing, so that resync scans
+// from the first unexpected byte.
+func (z *Reader) nextHeader() error {
+	rec := z.recovery
+	if rec != nil {
+		ok, err := z.atMember()
 		if err != nil {
-			return hdr, err
+			return err
+		}
+		if !ok {
+			return ErrHeader
 		}
 	}
-	return hdr, nil
+	hdr, err := z.startMember()
+	if err == nil && rec != nil && rec.noHeader {
+		z.Header = hdr
+		rec.noHeader = false
+	}
+	return err
 }
 
 // Read implements io.Reader, reading uncompressed bytes from its underlying Reader.
@@ -261,25 +346,48 @@ func (z *Reader) Read(p []byte) (n int, err error) {
 
 	for n == 0 {
 		n, z.err = z.decompressor.Read(p)
-		z.digest = crc32.Update(z.digest, crc32.IEEETable, p[:n])
 		z.size += uint32(n)
+		if z.recovery != nil {
+			z.recovery.remember(p[:n])
+		}
 		if z.err != io.EOF {
+			if z.err != nil && z.recovery != nil {
+				if z.err = z.salvage(z.err); z.err != nil {
+					return n, z.err
+				}
+				continue
+			}
 			// In the normal case we return here.
 			return n, z.err
 		}
-		if n, err := io.ReadFull(z.r, z.buf[:8]); err != nil {
+		if _, err := io.ReadFull(z.r, z.buf[:8]); err != nil {
 			z.err = noEOF(err)
+			if z.recovery != nil {
+				if z.err = z.salvage(z.err); z.err != nil {
+					return n, z.err
+				}
+				continue
+			}
 			return n, z.err
 		}
 		// Finished file; check checksum and size.
 
 		digest := le.Uint32(z.buf[:4])
 		size := le.Uint32(z.buf[4:8])
-		if digest != z.digest || size != z.size {
+		if (digest != z.sum.Sum32() || size != z.size) && (z.recovery == nil || !z.recovery.partial) {
 			z.err = ErrChecksum
+			if z.recovery != nil {
+				if z.err = z.salvage(z.err); z.err != nil {
+					return n, z.err
+				}
+				continue
+			}
 			return n, z.err
 		}
-		z.digest, z.size = 0, 0
+		z.size = 0
+		if z.recovery != nil {
+			z.recovery.partial = false
+		}
 
 		// File is ok; check if there is another.
 		if !z.multistream {
@@ -287,7 +395,23 @@ func (z *Reader) Read(p []byte) (n int, err error) {
 		}
 		z.err = nil // Remove io.EOF
 
-		if _, z.err = z.readHeader(); z.err != nil {
+		if z.allowTrail {
+			if ok, err := z.atMember(); !ok {
+				if err == nil {
+					z.trailing = true
+					err = io.EOF
+				}
+				z.err = err
+				return n, z.err
+			}
+		}
+		if z.err = z.nextHeader(); z.err != nil {
+			if z.err != io.EOF && z.recovery != nil {
+				if z.err = z.salvage(z.err); z.err != nil {
+					return n, z.err
+				}
+				continue
+			}
 			return n, z.err
 		}
 	}
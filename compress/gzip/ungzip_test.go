@@ -53,3 +53,55 @@ func TestGunzip(t *testing.T) {
 		t.Fatalf("expected %v got %v", multistreamFileMap, res)
 	}
 }
+
+func TestIgnoreTrailingGarbage(t *testing.T) {
+	var member bytes.Buffer
+	w := NewWriter(&member)
+	w.Write([]byte("hello world\n"))
+	w.Close()
+	twoMembers := append(append([]byte(nil), member.Bytes()...), member.Bytes()...)
+
+	for _, garbage := range []string{
+		"\x00\x00\x00\x00\x00\x00\x00\x00",
+		"unrelated trailer",
+		"\x1f",
+		"\x1f\x8b",
+	} {
+		input := append(append([]byte(nil), twoMembers...), garbage...)
+
+		r, err := NewReader(bytes.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(r); err == nil {
+			t.Errorf("garbage %q: default mode got nil error", garbage)
+		}
+
+		r, err = NewReader(bytes.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.IgnoreTrailingGarbage(true)
+		data, err := io.ReadAll(r)
+		if err != nil || string(data) != "hello world\nhello world\n" {
+			t.Errorf("garbage %q: ReadAll = %q, %v", garbage, data, err)
+			continue
+		}
+		rest, err := io.ReadAll(r.Trailing())
+		if err != nil || string(rest) != garbage {
+			t.Errorf("garbage %q: Trailing = %q, %v", garbage, rest, err)
+		}
+	}
+
+	r, err := NewReader(bytes.NewReader(twoMembers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.IgnoreTrailingGarbage(true)
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Trailing() != nil {
+		t.Errorf("Trailing is not nil without trailing data")
+	}
+}
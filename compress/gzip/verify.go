@@ -0,0 +1,72 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/intel/fastgo/compress/flate"
+	"github.com/intel/fastgo/internal/iocount"
+)
+
+// A Report summarizes a gzip stream checked by Verify.
+type Report struct {
+	CompressedSize   int64 // compressed bytes consumed
+	UncompressedSize int64 // bytes the stream decompresses to
+	Members          int   // number of members that passed their checks
+	ErrOffset        int64 // offset in the compressed input of the first error
+}
+
+// Verify checks every member of the gzip stream read from r, as reading it
+// with a Reader in multistream mode would, but without producing output: the
+// CRC-32 is computed directly on the data in the decoder's history buffer.
+// It is meant for integrity checks of archives.
+//
+// If the stream is damaged, Verify returns the error Read would return and
+// Report.ErrOffset holds the offset of the damaged header or trailer, or of
+// the point where decoding failed. Verify may read past the end of the stream.
+func Verify(r io.Reader) (Report, error) {
+	var (
+		rep Report
+		cr  = &iocount.Reader{R: r}
+		z   = Reader{r: bufio.NewReader(cr)}
+	)
+	offset := func() int64 {
+		return cr.N - int64(z.r.Buffered())
+	}
+	fail := func(at int64, err error) (Report, error) {
+		rep.CompressedSize = offset()
+		rep.ErrOffset = at
+		return rep, err
+	}
+	for {
+		start := offset()
+		if _, err := z.startMember(); err != nil {
+			if err == io.EOF && rep.Members > 0 {
+				break
+			}
+			return fail(start, noEOF(err))
+		}
+		dataStart := offset()
+		size, err := z.decompressor.(io.WriterTo).WriteTo(io.Discard)
+		rep.UncompressedSize += size
+		if err != nil {
+			if off, ok := err.(flate.CorruptInputError); ok {
+				return fail(dataStart+int64(off), err)
+			}
+			return fail(offset(), err)
+		}
+		trailer := offset()
+		if _, err := io.ReadFull(z.r, z.buf[:8]); err != nil {
+			return fail(offset(), noEOF(err))
+		}
+		if le.Uint32(z.buf[:4]) != z.sum.Sum32() || le.Uint32(z.buf[4:8]) != uint32(size) {
+			return fail(trailer, ErrChecksum)
+		}
+		rep.Members++
+	}
+	rep.CompressedSize = offset()
+	return rep, nil
+}
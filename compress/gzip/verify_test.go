@@ -0,0 +1,59 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/intel/fastgo/compress/flate"
+)
+
+func TestVerify(t *testing.T) {
+	a := recoveryPayload("first", 100000)
+	b := recoveryPayload("second", 1000)
+	data, starts := gzipMembers(t, false, a, b)
+
+	rep, err := Verify(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	want := Report{
+		CompressedSize:   int64(len(data)),
+		UncompressedSize: int64(len(a) + len(b)),
+		Members:          2,
+	}
+	if rep != want {
+		t.Fatalf("Verify = %+v, want %+v", rep, want)
+	}
+
+	bad := append([]byte(nil), data...)
+	bad[starts[1]-8] ^= 0xff
+	rep, err = Verify(bytes.NewReader(bad))
+	if err != ErrChecksum || rep.ErrOffset != int64(starts[1]-8) || rep.Members != 0 {
+		t.Fatalf("Verify bad checksum = %+v, %v", rep, err)
+	}
+
+	bad = append([]byte(nil), data...)
+	bad[starts[1]] = 0
+	rep, err = Verify(bytes.NewReader(bad))
+	if err != ErrHeader || rep.ErrOffset != int64(starts[1]) || rep.Members != 1 {
+		t.Fatalf("Verify bad header = %+v, %v", rep, err)
+	}
+
+	bad = append([]byte(nil), data...)
+	bad[starts[0]+10] |= 0x06 // reserved block type
+	rep, err = Verify(bytes.NewReader(bad))
+	if _, ok := err.(flate.CorruptInputError); !ok || rep.ErrOffset < int64(starts[0]+10) {
+		t.Fatalf("Verify corrupt data = %+v, %v", rep, err)
+	}
+
+	if _, err = Verify(bytes.NewReader(data[:len(data)-3])); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Verify truncated: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if _, err = Verify(bytes.NewReader(nil)); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Verify empty: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
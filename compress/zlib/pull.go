@@ -0,0 +1,89 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package zlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash"
+	"io"
+
+	"github.com/intel/fastgo/compress/flate"
+	"github.com/intel/fastgo/hash/adler32"
+)
+
+// A CompressingReader compresses the data of a source reader and returns
+// the zlib stream from its Read method, without a goroutine. It wraps a
+// flate.CompressingReader, whose description applies, between the zlib
+// header and trailer.
+type CompressingReader struct {
+	level   int
+	dict    []byte
+	fr      *flate.CompressingReader
+	digest  hash.Hash32
+	started bool         // the header is in out
+	out     bytes.Buffer // header or trailer not read yet
+	err     error        // returned once out is drained
+}
+
+// NewCompressingReader returns a CompressingReader compressing the data
+// read from src at the given level, which is checked like by
+// NewWriterLevel.
+func NewCompressingReader(src io.Reader, level int) (*CompressingReader, error) {
+	return NewCompressingReaderDict(src, level, nil)
+}
+
+// NewCompressingReaderDict is like NewCompressingReader but specifies a
+// dictionary to compress with, as NewWriterLevelDict does.
+func NewCompressingReaderDict(src io.Reader, level int, dict []byte) (*CompressingReader, error) {
+	if _, err := NewWriterLevelDict(nil, level, dict); err != nil {
+		return nil, err
+	}
+	fr, err := flate.NewCompressingReaderDict(src, level, dict)
+	if err != nil {
+		return nil, err
+	}
+	r := &CompressingReader{level: level, dict: dict, fr: fr, digest: adler32.New()}
+	fr.SetChecksum(r.digest)
+	return r, nil
+}
+
+// Read reads compressed data. It returns io.EOF once the whole stream,
+// trailer included, has been read. Errors of src are returned as is and
+// end the stream.
+func (r *CompressingReader) Read(p []byte) (int, error) {
+	if !r.started {
+		r.started = true
+		var hdr [6]byte
+		r.out.Write(appendHeader(hdr[:0], r.level, r.dict))
+	}
+	if r.out.Len() > 0 {
+		return r.out.Read(p)
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.fr.Read(p)
+	if err == io.EOF {
+		var trailer [4]byte
+		binary.BigEndian.PutUint32(trailer[:], r.digest.Sum32())
+		r.out.Write(trailer[:])
+		r.err = io.EOF
+		if n == 0 {
+			return r.out.Read(p)
+		}
+		err = nil
+	}
+	return n, err
+}
+
+// Reset discards the state of r and makes it compress the data read from
+// src, reusing its compressor.
+func (r *CompressingReader) Reset(src io.Reader) {
+	r.fr.Reset(src)
+	r.digest.Reset()
+	r.started = false
+	r.out.Reset()
+	r.err = nil
+}
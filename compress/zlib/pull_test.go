@@ -0,0 +1,52 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package zlib
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCompressingReader(t *testing.T) {
+	data := []byte(strings.Repeat("a zlib stream read from a reader\n", 20000))
+	dict := []byte("zlib stream reader")
+	for _, d := range [][]byte{nil, dict} {
+		for _, level := range []int{HuffmanOnly, BestSpeed, 2, DefaultCompression, BestCompression} {
+			r, err := NewCompressingReaderDict(iotest.HalfReader(bytes.NewReader(data)), level, d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			zr, err := zlib.NewReaderDict(r, d)
+			if err != nil {
+				t.Fatalf("level %d, dict %q: %v", level, d, err)
+			}
+			got, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatalf("level %d, dict %q: %v", level, d, err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("level %d, dict %q: round trip differs", level, d)
+			}
+
+			// A reset reader makes the same stream again.
+			r.Reset(bytes.NewReader(data))
+			if zr, err = zlib.NewReaderDict(r, d); err != nil {
+				t.Fatalf("level %d, dict %q, after Reset: %v", level, d, err)
+			}
+			if got, err = io.ReadAll(zr); err != nil || !bytes.Equal(got, data) {
+				t.Fatalf("level %d, dict %q, after Reset: %v", level, d, err)
+			}
+		}
+	}
+}
+
+func TestCompressingReaderLevel(t *testing.T) {
+	if _, err := NewCompressingReader(nil, 10); err == nil {
+		t.Fatal("accepted level 10")
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package zlib
+
+import (
+	"encoding/binary"
+	"hash"
+	"io"
+
+	"github.com/intel/fastgo/compress/flate"
+	"github.com/intel/fastgo/hash/adler32"
+)
+
+// Parts of a zlib stream an Inflater can be in.
+const (
+	inHeader = iota
+	inBody
+	inTrailer
+	inDone
+)
+
+// An Inflater decompresses a zlib stream written to it and writes the data
+// to a destination writer, without blocking for more input. It is the zlib
+// counterpart of flate.Inflater.
+type Inflater struct {
+	dst    io.Writer
+	dict   []byte
+	fi     *flate.Inflater
+	digest hash.Hash32 // Adler-32 of the data, fed by fi
+	part   int         // inHeader, inBody, inTrailer or inDone
+	buf    []byte      // partial header or trailer
+	err    error
+}
+
+// NewInflater returns an Inflater writing the decompressed data to dst.
+func NewInflater(dst io.Writer) *Inflater {
+	return NewInflaterDict(dst, nil)
+}
+
+// NewInflaterDict is like NewInflater but uses a preset dictionary, like
+// NewReaderDict.
+func NewInflaterDict(dst io.Writer, dict []byte) *Inflater {
+	z := &Inflater{}
+	z.Reset(dst, dict)
+	return z
+}
+
+// Reset discards the state of z and makes it decompress a new stream to
+// dst, with the preset dictionary dict.
+func (z *Inflater) Reset(dst io.Writer, dict []byte) {
+	*z = Inflater{
+		dst:    dst,
+		dict:   dict,
+		fi:     z.fi,
+		digest: z.digest,
+		buf:    z.buf[:0],
+	}
+}
+
+// Write decompresses p and writes the data that it completes to the
+// destination. Once the stream has ended, the rest of p is not used: Write
+// returns the number of bytes that belonged to the stream and
+// flate.ErrTrailingData, as it does for any later non-empty write. Invalid
+// input and errors of the destination stop z.
+func (z *Inflater) Write(p []byte) (n int, err error) {
+	for n < len(p) && z.err == nil {
+		var m int
+		switch z.part {
+		case inHeader:
+			m, z.err = z.writeHeader(p[n:])
+		case inBody:
+			m, z.err = z.fi.Write(p[n:])
+			if z.err == flate.ErrTrailingData || z.err == nil && z.fi.Done() {
+				z.err = nil
+				z.part = inTrailer
+			}
+		case inTrailer:
+			m, z.err = z.writeTrailer(p[n:])
+		case inDone:
+			return n, flate.ErrTrailingData
+		}
+		n += m
+	}
+	return n, z.err
+}
+
+// Close tells z that the input has ended. It returns io.ErrUnexpectedEOF if
+// the stream is not complete. It does not close the destination.
+func (z *Inflater) Close() error {
+	if z.err == nil && z.part != inDone {
+		z.err = io.ErrUnexpectedEOF
+	}
+	return z.err
+}
+
+// writeHeader gathers the header from p and starts the deflate stream once
+// it is complete. It returns the number of bytes of p it used.
+func (z *Inflater) writeHeader(p []byte) (int, error) {
+	size := 2
+	switch {
+	case len(z.buf) >= 2:
+		if z.buf[1]&0x20 != 0 {
+			size = 6 // with the dictionary's checksum
+		}
+	case len(z.buf)+len(p) >= 2:
+		if p[1-len(z.buf)]&0x20 != 0 {
+			size = 6
+		}
+	}
+	n := size - len(z.buf)
+	if n > len(p) {
+		n = len(p)
+	}
+	z.buf = append(z.buf, p[:n]...)
+	if len(z.buf) < size {
+		return n, nil
+	}
+	h := binary.BigEndian.Uint16(z.buf[:2])
+	if (z.buf[0]&0x0f != zlibDeflate) || (z.buf[0]>>4 > zlibMaxWindow) || (h%31 != 0) {
+		return n, ErrHeader
+	}
+	var dict []byte
+	if size == 6 {
+		if binary.BigEndian.Uint32(z.buf[2:6]) != adler32.Checksum(z.dict) {
+			return n, ErrDictionary
+		}
+		dict = z.dict
+	}
+	z.buf = z.buf[:0]
+	if z.digest == nil {
+		z.digest = adler32.New()
+	} else {
+		z.digest.Reset()
+	}
+	if z.fi == nil {
+		z.fi = flate.NewInflaterDict(z.dst, dict)
+	} else {
+		z.fi.Reset(z.dst, dict)
+	}
+	z.fi.SetChecksum(z.digest)
+	z.part = inBody
+	return n, nil
+}
+
+// writeTrailer gathers the checksum from p and checks it once it is
+// complete. It returns the number of bytes of p it used.
+func (z *Inflater) writeTrailer(p []byte) (int, error) {
+	n := 4 - len(z.buf)
+	if n > len(p) {
+		n = len(p)
+	}
+	z.buf = append(z.buf, p[:n]...)
+	if len(z.buf) < 4 {
+		return n, nil
+	}
+	z.part = inDone
+	if binary.BigEndian.Uint32(z.buf) != z.digest.Sum32() {
+		return n, ErrChecksum
+	}
+	return n, nil
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package zlib
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/intel/fastgo/compress/flate"
+)
+
+func TestInflater(t *testing.T) {
+	data := []byte(strings.Repeat("a zlib stream written in pieces\n", 10000))
+	dict := []byte("zlib stream pieces")
+	rnd := rand.New(rand.NewSource(1))
+	for _, d := range [][]byte{nil, dict} {
+		var in bytes.Buffer
+		w, _ := zlib.NewWriterLevelDict(&in, zlib.BestSpeed, d)
+		w.Write(data)
+		w.Close()
+		for _, max := range []int{1, 3, 300, 1 << 20} {
+			var out bytes.Buffer
+			z := NewInflaterDict(&out, d)
+			for b := in.Bytes(); len(b) > 0; {
+				n := 1 + rnd.Intn(max)
+				if n > len(b) {
+					n = len(b)
+				}
+				if _, err := z.Write(b[:n]); err != nil {
+					t.Fatalf("dict %q, pieces of %d: %v", d, max, err)
+				}
+				b = b[n:]
+			}
+			if err := z.Close(); err != nil {
+				t.Fatalf("dict %q, pieces of %d: %v", d, max, err)
+			}
+			if !bytes.Equal(out.Bytes(), data) {
+				t.Fatalf("dict %q, pieces of %d: round trip differs", d, max)
+			}
+		}
+	}
+}
+
+func TestInflaterErrors(t *testing.T) {
+	var in bytes.Buffer
+	w := NewWriter(&in)
+	w.Write([]byte("payload"))
+	w.Close()
+	stream := append([]byte(nil), in.Bytes()...)
+	in.Reset()
+	w, _ = NewWriterLevelDict(&in, BestSpeed, []byte("dict"))
+	w.Write([]byte("payload"))
+	w.Close()
+
+	tests := []struct {
+		name  string
+		input []byte
+		write error
+		close error
+	}{
+		{"empty", nil, nil, io.ErrUnexpectedEOF},
+		{"header", stream[:1], nil, io.ErrUnexpectedEOF},
+		{"body", stream[:5], nil, io.ErrUnexpectedEOF},
+		{"trailer", stream[:len(stream)-1], nil, io.ErrUnexpectedEOF},
+		{"bad header", []byte{0x78, 0x9d}, ErrHeader, ErrHeader},
+		{"dictionary", in.Bytes(), ErrDictionary, ErrDictionary},
+		{"checksum", append(stream[:len(stream)-1:len(stream)-1], stream[len(stream)-1]+1), ErrChecksum, ErrChecksum},
+		{"trailing", append(stream[:len(stream):len(stream)], 0), flate.ErrTrailingData, nil},
+	}
+	z := NewInflater(io.Discard)
+	for _, tt := range tests {
+		z.Reset(io.Discard, nil)
+		n, err := z.Write(tt.input)
+		if err != tt.write {
+			t.Fatalf("%s: Write = %v, want %v", tt.name, err, tt.write)
+		}
+		if err == flate.ErrTrailingData && n != len(stream) {
+			t.Fatalf("%s: Write used %d bytes, want %d", tt.name, n, len(stream))
+		}
+		if err := z.Close(); err != tt.close {
+			t.Fatalf("%s: Close = %v, want %v", tt.name, err, tt.close)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package zlib
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/intel/fastgo/compress/flate"
+	"github.com/intel/fastgo/hash/adler32"
+	"github.com/intel/fastgo/internal/iocount"
+)
+
+// A Report summarizes a zlib stream checked by Verify.
+type Report struct {
+	CompressedSize   int64 // compressed bytes consumed
+	UncompressedSize int64 // bytes the stream decompresses to
+	ErrOffset        int64 // offset in the compressed input of the first error
+}
+
+// Verify checks the zlib stream read from r, as reading it with the
+// ReadCloser returned by NewReader would, but without producing output: the
+// Adler-32 checksum is computed directly on the data in the decoder's history
+// buffer. Streams that need a preset dictionary fail with ErrDictionary.
+//
+// If the stream is damaged, Verify returns the error Read would return and
+// Report.ErrOffset holds the offset of the damaged header or trailer, or of
+// the point where decoding failed. Verify may read past the end of the stream.
+func Verify(r io.Reader) (Report, error) {
+	var (
+		rep     Report
+		scratch [4]byte
+		cr      = &iocount.Reader{R: r}
+		br      = bufio.NewReader(cr)
+	)
+	offset := func() int64 {
+		return cr.N - int64(br.Buffered())
+	}
+	fail := func(at int64, err error) (Report, error) {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		rep.CompressedSize = offset()
+		rep.ErrOffset = at
+		return rep, err
+	}
+
+	// Read the header (RFC 1950 section 2.2.).
+	if _, err := io.ReadFull(br, scratch[:2]); err != nil {
+		return fail(offset(), err)
+	}
+	h := binary.BigEndian.Uint16(scratch[:2])
+	if (scratch[0]&0x0f != zlibDeflate) || (scratch[0]>>4 > zlibMaxWindow) || (h%31 != 0) {
+		return fail(0, ErrHeader)
+	}
+	if scratch[1]&0x20 != 0 {
+		return fail(0, ErrDictionary)
+	}
+
+	digest := adler32.New()
+	f := flate.NewReader(br)
+	f.(flate.Checksummer).SetChecksum(digest)
+	size, err := f.(io.WriterTo).WriteTo(io.Discard)
+	rep.UncompressedSize = size
+	if err != nil {
+		if off, ok := err.(flate.CorruptInputError); ok {
+			return fail(2+int64(off), err)
+		}
+		return fail(offset(), err)
+	}
+	trailer := offset()
+	if _, err := io.ReadFull(br, scratch[:4]); err != nil {
+		return fail(offset(), err)
+	}
+	// ZLIB (RFC 1950) is big-endian, unlike GZIP (RFC 1952).
+	if binary.BigEndian.Uint32(scratch[:4]) != digest.Sum32() {
+		return fail(trailer, ErrChecksum)
+	}
+	rep.CompressedSize = offset()
+	return rep, nil
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package zlib
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	payload := bytes.Repeat([]byte("verify zlib data\n"), 10000)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write(payload)
+	w.Close()
+	data := buf.Bytes()
+
+	rep, err := Verify(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	want := Report{CompressedSize: int64(len(data)), UncompressedSize: int64(len(payload))}
+	if rep != want {
+		t.Fatalf("Verify = %+v, want %+v", rep, want)
+	}
+
+	bad := append([]byte(nil), data...)
+	bad[len(bad)-1] ^= 0xff
+	rep, err = Verify(bytes.NewReader(bad))
+	if err != ErrChecksum || rep.ErrOffset != int64(len(data)-4) {
+		t.Fatalf("Verify bad checksum = %+v, %v", rep, err)
+	}
+
+	if _, err = Verify(bytes.NewReader([]byte{0x78, 0x00})); err != ErrHeader {
+		t.Fatalf("Verify bad header: got %v, want %v", err, ErrHeader)
+	}
+	if _, err = Verify(bytes.NewReader(data[:len(data)-2])); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Verify truncated: got %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
@@ -8,10 +8,10 @@ import (
 	"encoding/binary"
 	"fmt"
 	"hash"
-	"hash/adler32"
 	"io"
 
 	"github.com/intel/fastgo/compress/flate"
+	"github.com/intel/fastgo/hash/adler32"
 )
 
 // These constants are copied from the flate package, so that code that imports
@@ -90,53 +90,85 @@ func (z *Writer) Reset(w io.Writer) {
 	z.wroteHeader = false
 }
 
+// SetLevel changes the compression level of the data written from now on,
+// without starting a new stream, as described for flate.Writer.SetLevel.
+// The level stays in effect after Reset. It must not be called after Close,
+// until Reset.
+func (z *Writer) SetLevel(level int) error {
+	if level < HuffmanOnly || level > BestCompression {
+		return fmt.Errorf("zlib: invalid compression level: %d", level)
+	}
+	if z.err != nil {
+		return z.err
+	}
+	if z.compressor != nil {
+		// Before the header, the compressor was just reset and writes
+		// nothing as it switches.
+		if err := z.compressor.SetLevel(level); err != nil {
+			return err
+		}
+	}
+	z.level = level
+	return nil
+}
+
 // writeHeader writes the ZLIB header.
 func (z *Writer) writeHeader() (err error) {
 	z.wroteHeader = true
+	var hdr [6]byte
+	if _, err = z.w.Write(appendHeader(hdr[:0], z.level, z.dict)); err != nil {
+		return err
+	}
+	if z.compressor == nil {
+		// Initialize deflater unless the Writer is being reused
+		// after a Reset call.
+		z.compressor, err = flate.NewWriterDict(z.w, z.level, z.dict)
+		if err != nil {
+			return err
+		}
+		// The compressor updates the checksum as it copies the input.
+		z.digest = adler32.New()
+		z.compressor.SetChecksum(z.digest)
+	}
+	return nil
+}
+
+// appendHeader appends the ZLIB header of a stream compressed at level with
+// dict to b.
+func appendHeader(b []byte, level int, dict []byte) []byte {
 	// ZLIB has a two-byte header (as documented in RFC 1950).
 	// The first four bits is the CINFO (compression info), which is 7 for the default deflate window size.
 	// The next four bits is the CM (compression method), which is 8 for deflate.
-	z.scratch[0] = 0x78
+	var h [2]byte
+	h[0] = 0x78
 	// The next two bits is the FLEVEL (compression level). The four values are:
 	// 0=fastest, 1=fast, 2=default, 3=best.
 	// The next bit, FDICT, is set if a dictionary is given.
 	// The final five FCHECK bits form a mod-31 checksum.
-	switch z.level {
+	switch level {
 	case -2, 0, 1:
-		z.scratch[1] = 0 << 6
+		h[1] = 0 << 6
 	case 2, 3, 4, 5:
-		z.scratch[1] = 1 << 6
+		h[1] = 1 << 6
 	case 6, -1:
-		z.scratch[1] = 2 << 6
+		h[1] = 2 << 6
 	case 7, 8, 9:
-		z.scratch[1] = 3 << 6
+		h[1] = 3 << 6
 	default:
 		panic("unreachable")
 	}
-	if z.dict != nil {
-		z.scratch[1] |= 1 << 5
-	}
-	z.scratch[1] += uint8(31 - binary.BigEndian.Uint16(z.scratch[:2])%31)
-	if _, err = z.w.Write(z.scratch[0:2]); err != nil {
-		return err
+	if dict != nil {
+		h[1] |= 1 << 5
 	}
-	if z.dict != nil {
+	h[1] += uint8(31 - binary.BigEndian.Uint16(h[:])%31)
+	b = append(b, h[:]...)
+	if dict != nil {
 		// The next four bytes are the Adler-32 checksum of the dictionary.
-		binary.BigEndian.PutUint32(z.scratch[:], adler32.Checksum(z.dict))
-		if _, err = z.w.Write(z.scratch[0:4]); err != nil {
-			return err
-		}
+		var sum [4]byte
+		binary.BigEndian.PutUint32(sum[:], adler32.Checksum(dict))
+		b = append(b, sum[:]...)
 	}
-	if z.compressor == nil {
-		// Initialize deflater unless the Writer is being reused
-		// after a Reset call.
-		z.compressor, err = flate.NewWriterDict(z.w, z.level, z.dict)
-		if err != nil {
-			return err
-		}
-		z.digest = adler32.New()
-	}
-	return nil
+	return b
 }
 
 // Write writes a compressed form of p to the underlying io.Writer. The
@@ -155,9 +187,7 @@ func (z *Writer) Write(p []byte) (n int, err error) {
 	n, err = z.compressor.Write(p)
 	if err != nil {
 		z.err = err
-		return
 	}
-	z.digest.Write(p)
 	return
 }
 
@@ -227,3 +227,82 @@ func TestWriterDictIsUsed(t *testing.T) {
 func testenvBuilder() string {
 	return os.Getenv("GO_BUILDER_NAME")
 }
+
+func TestWriterSetLevel(t *testing.T) {
+	input := bytes.Repeat([]byte("zlib output at changing levels\n"), 5000)
+	dict := []byte("zlib output")
+	for _, d := range [][]byte{nil, dict} {
+		var buf bytes.Buffer
+		w, _ := NewWriterLevelDict(&buf, 6, d)
+		if err := w.SetLevel(BestSpeed); err != nil {
+			t.Fatalf("SetLevel: %v", err)
+		}
+		var want []byte
+		for _, level := range []int{9, HuffmanOnly, 2, NoCompression} {
+			w.Write(input)
+			want = append(want, input...)
+			if err := w.SetLevel(level); err != nil {
+				t.Fatalf("SetLevel(%d): %v", level, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		r, err := NewReaderDict(&buf, d)
+		if err != nil {
+			t.Fatalf("NewReaderDict: %v", err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("dict %q: round trip mismatch: %v", d, err)
+		}
+	}
+	w := NewWriter(io.Discard)
+	if err := w.SetLevel(10); err == nil {
+		t.Fatalf("SetLevel(10): got nil error")
+	}
+}
+
+// TestWriterSetLevelReset checks that SetLevel keeps the compressor of a
+// Writer that was reset, and that the output is that of a Writer made at
+// the new level.
+func TestWriterSetLevelReset(t *testing.T) {
+	input := bytes.Repeat([]byte("zlib output after a reset\n"), 5000)
+	dict := []byte("zlib output")
+	for _, d := range [][]byte{nil, dict} {
+		compress := func(level int) []byte {
+			var buf bytes.Buffer
+			w, _ := NewWriterLevelDict(&buf, level, d)
+			w.Write(input)
+			w.Close()
+			return buf.Bytes()
+		}
+		var buf bytes.Buffer
+		w, _ := NewWriterLevelDict(&buf, 6, d)
+		w.Write(input)
+		w.Close()
+		c := w.compressor
+		for _, level := range []int{BestSpeed, HuffmanOnly, 6, BestCompression, 2} {
+			buf.Reset()
+			w.Reset(&buf)
+			if err := w.SetLevel(level); err != nil {
+				t.Fatalf("SetLevel(%d) after Reset: %v", level, err)
+			}
+			w.Write(input)
+			w.Close()
+			if w.compressor != c {
+				t.Fatalf("SetLevel(%d) after Reset replaced the compressor", level)
+			}
+			r, err := NewReaderDict(bytes.NewReader(buf.Bytes()), d)
+			if err != nil {
+				t.Fatalf("NewReaderDict: %v", err)
+			}
+			if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, input) {
+				t.Fatalf("dict %q, level %d after Reset: round trip mismatch: %v", d, level, err)
+			}
+			if d == nil && !bytes.Equal(buf.Bytes(), compress(level)) {
+				t.Fatalf("level %d after Reset: output differs from a new Writer", level)
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package fastgo
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/intel/fastgo/compress/flate"
+)
+
+// CompressedConn is a net.Conn whose traffic is compressed with DEFLATE
+// (RFC 1951): each direction of the connection carries one raw DEFLATE
+// stream. It is meant for links between trusted peers, such as replication
+// links, where bandwidth is scarce and encryption, if any, is done
+// elsewhere. Both ends of the connection must be a CompressedConn or speak
+// the same format.
+//
+// By default every Write ends with a sync flush, so that the peer can read
+// the data as soon as it arrives. With SetAutoFlush(false), data is only
+// sent once the compressor's buffers fill up or Flush is called, which
+// compresses better when many small writes make up one message.
+//
+// Reads decompress whatever input has arrived and return as soon as some
+// data is available. An error of the underlying connection, such as a read
+// deadline expiring, is returned by Read but does not end the stream: Read
+// may be called again, for instance after extending the deadline. A write
+// error on the other hand leaves the outgoing stream in an unknown state,
+// and the connection should be closed.
+//
+// Deadlines and addresses are those of the underlying connection.
+type CompressedConn struct {
+	net.Conn
+
+	rmu sync.Mutex
+	fr  io.ReadCloser
+
+	wmu       sync.Mutex
+	bw        *bufio.Writer
+	fw        *flate.Writer
+	autoFlush bool
+
+	// smu guards closed and writing, which Close reads without taking wmu
+	// so as not to wait for a Write blocked on the connection.
+	smu     sync.Mutex
+	closed  bool
+	writing bool
+}
+
+// NewCompressedConn returns a CompressedConn wrapping c, compressing the
+// data written to it at the given level. Any level accepted by
+// flate.NewWriter is valid.
+func NewCompressedConn(c net.Conn, level int) (*CompressedConn, error) {
+	// The compressed data goes through a buffer so that each flush reaches
+	// the connection in a single write.
+	bw := bufio.NewWriter(c)
+	fw, err := flate.NewWriter(bw, level)
+	if err != nil {
+		return nil, err
+	}
+	return &CompressedConn{
+		Conn:      c,
+		fr:        flate.NewReader(c),
+		bw:        bw,
+		fw:        fw,
+		autoFlush: true,
+	}, nil
+}
+
+// Read reads and decompresses data from the connection.
+func (c *CompressedConn) Read(p []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	return c.fr.Read(p)
+}
+
+// Write compresses p and writes it to the connection, flushing it unless
+// auto flush is off.
+func (c *CompressedConn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if !c.begin() {
+		return 0, net.ErrClosed
+	}
+	defer c.end()
+	n, err := c.fw.Write(p)
+	if err == nil && c.autoFlush {
+		err = c.flush()
+	}
+	return n, err
+}
+
+// Flush sends all data written so far to the peer, ending it with a sync
+// flush so that it can be read right away.
+func (c *CompressedConn) Flush() error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if !c.begin() {
+		return net.ErrClosed
+	}
+	defer c.end()
+	return c.flush()
+}
+
+// begin marks the start of a write to the connection, unless c is closed.
+// It is called with wmu held.
+func (c *CompressedConn) begin() bool {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	if c.closed {
+		return false
+	}
+	c.writing = true
+	return true
+}
+
+func (c *CompressedConn) end() {
+	c.smu.Lock()
+	c.writing = false
+	c.smu.Unlock()
+}
+
+func (c *CompressedConn) flush() error {
+	if err := c.fw.Flush(); err != nil {
+		return err
+	}
+	return c.bw.Flush()
+}
+
+// SetAutoFlush sets whether every Write is followed by a Flush, which is
+// the default.
+func (c *CompressedConn) SetAutoFlush(on bool) {
+	c.wmu.Lock()
+	c.autoFlush = on
+	c.wmu.Unlock()
+}
+
+// Close ends the outgoing stream, so that the peer reads io.EOF once it
+// has read all data, and closes the underlying connection. Ending the
+// stream is a write, which a write deadline can bound. If a Write or Flush
+// is in progress, Close does not wait for it: it closes the underlying
+// connection right away, which makes the pending call fail, and the peer
+// reads io.ErrUnexpectedEOF as the stream is not ended.
+func (c *CompressedConn) Close() error {
+	c.smu.Lock()
+	closed, busy := c.closed, c.writing
+	c.closed = true
+	c.smu.Unlock()
+	var err error
+	if !closed && !busy {
+		// A Write holding wmu now sees c closed and returns at once.
+		c.wmu.Lock()
+		err = c.fw.Close()
+		if err == nil {
+			err = c.bw.Flush()
+		}
+		c.wmu.Unlock()
+	}
+	if cerr := c.Conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
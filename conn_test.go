@@ -0,0 +1,165 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package fastgo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func connPair(t *testing.T, level int) (*CompressedConn, *CompressedConn) {
+	c1, c2 := net.Pipe()
+	a, err := NewCompressedConn(c1, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewCompressedConn(c2, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, b
+}
+
+// closePair closes a, then b, reading what b receives meanwhile since the
+// pipe does not buffer the end of the stream.
+func closePair(a, b *CompressedConn) {
+	go io.Copy(io.Discard, b)
+	a.Close()
+	b.Close()
+}
+
+func TestCompressedConnEcho(t *testing.T) {
+	if _, err := NewCompressedConn(nil, 42); err == nil {
+		t.Fatal("NewCompressedConn accepted level 42")
+	}
+	for _, level := range []int{1, 2, 6, 9} {
+		client, server := connPair(t, level)
+		go func() {
+			br := bufio.NewReader(server)
+			for {
+				line, err := br.ReadString('\n')
+				if err != nil {
+					server.Close()
+					return
+				}
+				server.Write([]byte(strings.ToUpper(line)))
+			}
+		}()
+		br := bufio.NewReader(client)
+		for i := 0; i < 100; i++ {
+			msg := fmt.Sprintf("message %d %s\n", i, strings.Repeat("x", i*i))
+			if _, err := client.Write([]byte(msg)); err != nil {
+				t.Fatal(err)
+			}
+			reply, err := br.ReadString('\n')
+			if err != nil || reply != strings.ToUpper(msg) {
+				t.Fatalf("level %d: reply %d: %q, %v", level, i, reply, err)
+			}
+		}
+		client.Close()
+	}
+}
+
+func TestCompressedConnFlush(t *testing.T) {
+	a, b := connPair(t, 1)
+	defer closePair(a, b)
+	a.SetAutoFlush(false)
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 10; i++ {
+			if _, err := fmt.Fprintf(a, "part %d;", i); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- a.Flush()
+	}()
+	want := "part 0;part 1;part 2;part 3;part 4;part 5;part 6;part 7;part 8;part 9;"
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(b, got); err != nil || string(got) != want {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompressedConnDeadline(t *testing.T) {
+	a, b := connPair(t, 1)
+	defer closePair(a, b)
+	b.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	buf := make([]byte, 10)
+	if _, err := b.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Read = %v, want a timeout", err)
+	}
+	// The stream survives the timeout.
+	b.SetReadDeadline(time.Time{})
+	go a.Write([]byte("hello"))
+	if n, err := b.Read(buf); err != nil || string(buf[:n]) != "hello" {
+		t.Fatalf("Read = %q, %v", buf[:n], err)
+	}
+}
+
+func TestCompressedConnStream(t *testing.T) {
+	a, b := connPair(t, 2)
+	var want bytes.Buffer
+	for i := 0; want.Len() < 4<<20; i++ {
+		fmt.Fprintf(&want, "record %d: %x\n", i, i*i*7919)
+	}
+	go func() {
+		data := want.Bytes()
+		for len(data) > 0 {
+			n := 100000
+			if n > len(data) {
+				n = len(data)
+			}
+			a.Write(data[:n])
+			data = data[n:]
+		}
+		a.Close()
+	}()
+	got, err := io.ReadAll(b)
+	if err != nil || !bytes.Equal(got, want.Bytes()) {
+		t.Fatalf("got %d bytes, want %d, %v", len(got), want.Len(), err)
+	}
+	if _, err := a.Write([]byte("late")); err == nil {
+		t.Fatal("Write succeeded after Close")
+	}
+	b.Close()
+}
+
+func TestCompressedConnCloseBlockedWrite(t *testing.T) {
+	a, b := connPair(t, 1)
+	defer b.Close()
+	// Nothing reads from b, so the Write blocks on the pipe.
+	done := make(chan error)
+	go func() {
+		_, err := a.Write(bytes.Repeat([]byte("data"), 1000))
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	closed := make(chan error)
+	go func() { closed <- a.Close() }()
+	for _, ch := range []chan error{done, closed} {
+		select {
+		case err := <-ch:
+			if ch == done && err == nil {
+				t.Fatal("blocked Write succeeded after Close")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Close did not unblock the pending Write")
+		}
+	}
+	if _, err := a.Write([]byte("late")); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Write after Close = %v, want net.ErrClosed", err)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package gzip implements a gRPC compressor using the Intel-optimized fastgo
+// gzip package.
+//
+// Importing the package registers the compressor under the name "gzip",
+// replacing the one of google.golang.org/grpc/encoding/gzip, which must
+// not be imported after it:
+//
+//	import _ "github.com/intel/fastgo/grpc/gzip"
+//
+// Clients enable it per call with grpc.UseCompressor(gzip.Name), and
+// servers answer compressed requests with compressed responses.
+package gzip
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/intel/fastgo/compress/gzip"
+	"google.golang.org/grpc/encoding"
+)
+
+// Name is the name the compressor is registered under.
+const Name = "gzip"
+
+func init() {
+	encoding.RegisterCompressor(&compressor{level: gzip.DefaultCompression})
+}
+
+// SetLevel sets the compression level of the registered compressor. It
+// accepts the levels of gzip.NewWriterLevel. Like its counterpart in
+// google.golang.org/grpc/encoding/gzip, it is meant to be called at
+// initialization time, before any RPC is made; calls made later are safe,
+// and take effect for the messages compressed after them.
+func SetLevel(level int) error {
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		return fmt.Errorf("grpc: invalid gzip compression level: %d", level)
+	}
+	c := encoding.GetCompressor(Name).(*compressor)
+	atomic.StoreInt32(&c.level, int32(level))
+	return nil
+}
+
+// compressor implements encoding.Compressor, pooling writers and readers
+// and reusing them through Reset.
+type compressor struct {
+	level            int32 // read and written atomically
+	poolCompressor   sync.Pool
+	poolDecompressor sync.Pool
+}
+
+// writer returns itself to the pool once closed.
+type writer struct {
+	*gzip.Writer
+	level int32
+	pool  *sync.Pool
+}
+
+// reader returns itself to the pool once closed. gRPC closes the readers
+// returned by Decompress when it is done with a message. Unlike writer it
+// does not embed its gzip.Reader, whose Close would otherwise be promoted.
+type reader struct {
+	zr   *gzip.Reader
+	pool *sync.Pool
+}
+
+func (c *compressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	level := atomic.LoadInt32(&c.level)
+	z, ok := c.poolCompressor.Get().(*writer)
+	if !ok || z.level != level {
+		// Writers pooled before a SetLevel are dropped. The level was
+		// checked by SetLevel.
+		zw, _ := gzip.NewWriterLevel(w, int(level))
+		return &writer{Writer: zw, level: level, pool: &c.poolCompressor}, nil
+	}
+	z.Writer.Reset(w)
+	return z, nil
+}
+
+func (z *writer) Close() error {
+	defer z.pool.Put(z)
+	return z.Writer.Close()
+}
+
+func (c *compressor) Decompress(r io.Reader) (io.Reader, error) {
+	z, ok := c.poolDecompressor.Get().(*reader)
+	if !ok {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return &reader{zr: zr, pool: &c.poolDecompressor}, nil
+	}
+	if err := z.zr.Reset(r); err != nil {
+		c.poolDecompressor.Put(z)
+		return nil, err
+	}
+	return z, nil
+}
+
+func (z *reader) Read(p []byte) (int, error) {
+	return z.zr.Read(p)
+}
+
+func (z *reader) Close() error {
+	z.pool.Put(z)
+	return nil
+}
+
+func (c *compressor) Name() string {
+	return Name
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package gzip
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/intel/fastgo/compress/gzip"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type echoServer struct {
+	testpb.UnimplementedTestServiceServer
+}
+
+func (echoServer) UnaryCall(ctx context.Context, req *testpb.SimpleRequest) (*testpb.SimpleResponse, error) {
+	return &testpb.SimpleResponse{Payload: req.Payload}, nil
+}
+
+// payloadStats records the wire and decoded sizes of received messages.
+type payloadStats struct {
+	mu         sync.Mutex
+	compressed int
+	length     int
+}
+
+func (s *payloadStats) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context { return ctx }
+func (s *payloadStats) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+func (s *payloadStats) HandleConn(context.Context, stats.ConnStats) {}
+
+func (s *payloadStats) HandleRPC(_ context.Context, rs stats.RPCStats) {
+	if in, ok := rs.(*stats.InPayload); ok {
+		s.mu.Lock()
+		s.compressed += in.CompressedLength
+		s.length += in.Length
+		s.mu.Unlock()
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	if _, ok := encoding.GetCompressor(Name).(*compressor); !ok {
+		t.Fatalf("compressor %q is %T", Name, encoding.GetCompressor(Name))
+	}
+	if err := SetLevel(42); err == nil {
+		t.Fatal("SetLevel accepted level 42")
+	}
+}
+
+func TestBufconn(t *testing.T) {
+	if err := SetLevel(1); err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	var serverStats, clientStats payloadStats
+	srv := grpc.NewServer(grpc.StatsHandler(&serverStats))
+	testpb.RegisterTestServiceServer(srv, echoServer{})
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(&clientStats),
+		grpc.WithDefaultCallOptions(grpc.UseCompressor(Name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := testpb.NewTestServiceClient(conn)
+
+	body := bytes.Repeat([]byte("compressible gRPC payload "), 4000)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				resp, err := client.UnaryCall(context.Background(), &testpb.SimpleRequest{
+					Payload: &testpb.Payload{Body: body},
+				})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(resp.GetPayload().GetBody(), body) {
+					t.Error("payload changed in the round trip")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, s := range []*payloadStats{&serverStats, &clientStats} {
+		if s.length == 0 || s.compressed*10 > s.length {
+			t.Fatalf("received %d bytes for %d bytes of messages", s.compressed, s.length)
+		}
+	}
+}
+
+func TestSetLevelPooled(t *testing.T) {
+	defer SetLevel(gzip.DefaultCompression)
+	c := encoding.GetCompressor(Name)
+	data := bytes.Repeat([]byte("pooled writer "), 1000)
+	compress := func() int {
+		var buf bytes.Buffer
+		w, err := c.Compress(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+		w.Close() // back to the pool
+		return buf.Len()
+	}
+	SetLevel(gzip.BestSpeed)
+	fast := compress()
+	SetLevel(gzip.HuffmanOnly)
+	// Huffman-only output does not use back references, so it is much
+	// larger, unless the pooled BestSpeed writer was reused.
+	if huff := compress(); huff < 4*fast {
+		t.Fatalf("after SetLevel(HuffmanOnly): %d bytes, BestSpeed gave %d", huff, fast)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build amd64 && !noasmtest
+// +build amd64,!noasmtest
+
+// This file contains the AVX2 and AVX-512 implementations of Adler-32,
+// selected according to the detected CPU architecture level.
+package adler32
+
+import "github.com/intel/fastgo/internal/cpu"
+
+// Each vector loop sums whole blocks of its register width and defers the
+// modulo to the end of a chunk of at most nmax bytes, rounded down to a
+// whole number of blocks, so that no 32-bit sum can overflow.
+const (
+	blockV3 = 32
+	blockV4 = 64
+	chunkV3 = nmax &^ (blockV3 - 1)
+	chunkV4 = nmax &^ (blockV4 - 1)
+)
+
+// updateArchV3 adds p to the sums s1 and s2 using AVX2. len(p) must be a
+// non-zero multiple of 32 and at most chunkV3. The sums are not reduced.
+func updateArchV3(s1, s2 uint32, p []byte) (uint32, uint32)
+
+// updateArchV4 adds p to the sums s1 and s2 using AVX-512. len(p) must be a
+// non-zero multiple of 64 and at most chunkV4. The sums are not reduced.
+func updateArchV4(s1, s2 uint32, p []byte) (uint32, uint32)
+
+func update(d digest, p []byte) digest {
+	var block, chunk int
+	switch {
+	case cpu.ArchLevel >= 4:
+		block, chunk = blockV4, chunkV4
+	case cpu.ArchLevel >= 3:
+		block, chunk = blockV3, chunkV3
+	default:
+		return updateGeneric(d, p)
+	}
+	s1, s2 := uint32(d&0xffff), uint32(d>>16)
+	for len(p) >= block {
+		n := len(p) &^ (block - 1)
+		if n > chunk {
+			n = chunk
+		}
+		if block == blockV4 {
+			s1, s2 = updateArchV4(s1, s2, p[:n])
+		} else {
+			s1, s2 = updateArchV3(s1, s2, p[:n])
+		}
+		s1 %= mod
+		s2 %= mod
+		p = p[n:]
+	}
+	// Finish the tail that does not fill a block.
+	return updateGeneric(digest(s2<<16|s1), p)
+}
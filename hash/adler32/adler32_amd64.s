@@ -0,0 +1,138 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build amd64 && !noasmtest
+// +build amd64,!noasmtest
+
+#include "textflag.h"
+
+// The sums over a block of n bytes b[0..n-1] are
+//   s1' = s1 + sum(b[i])
+//   s2' = s2 + n*s1 + sum((n-i) * b[i])
+// The loops below keep per-lane partial sums of the bytes in vs1 and of the
+// weighted bytes in vs2, and accumulate vs1 before each block in vs3, which
+// is scaled by the block size at the end. The n*s1 term for the incoming s1
+// is added once up front.
+
+// weights holds the byte weights 64, 63, ..., 1. The AVX2 loop uses its
+// second half.
+DATA weights<>+0(SB)/8, $0x393a3b3c3d3e3f40
+DATA weights<>+8(SB)/8, $0x3132333435363738
+DATA weights<>+16(SB)/8, $0x292a2b2c2d2e2f30
+DATA weights<>+24(SB)/8, $0x2122232425262728
+DATA weights<>+32(SB)/8, $0x191a1b1c1d1e1f20
+DATA weights<>+40(SB)/8, $0x1112131415161718
+DATA weights<>+48(SB)/8, $0x090a0b0c0d0e0f10
+DATA weights<>+56(SB)/8, $0x0102030405060708
+GLOBL weights<>(SB), RODATA|NOPTR, $64
+
+// REDUCE adds the four lanes of X1 to AX and of X2 to BX and returns them.
+#define REDUCE \
+	VPSHUFD $0x4e, X1, X6 \
+	VPADDD  X6, X1, X1    \
+	VPSHUFD $0xb1, X1, X6 \
+	VPADDD  X6, X1, X1    \
+	VPSHUFD $0x4e, X2, X7 \
+	VPADDD  X7, X2, X2    \
+	VPSHUFD $0xb1, X2, X7 \
+	VPADDD  X7, X2, X2    \
+	VMOVD   X1, DX        \
+	ADDL    DX, AX        \
+	VMOVD   X2, DX        \
+	ADDL    DX, BX        \
+	VZEROUPPER            \
+	MOVL    AX, ret+32(FP) \
+	MOVL    BX, ret1+36(FP) \
+	RET
+
+// func updateArchV3(s1, s2 uint32, p []byte) (uint32, uint32)
+// Requires: AVX, AVX2
+TEXT ·updateArchV3(SB), NOSPLIT, $0-40
+	MOVL s1+0(FP), AX
+	MOVL s2+4(FP), BX
+	MOVQ p_base+8(FP), SI
+	MOVQ p_len+16(FP), CX
+
+	// s2 += len(p) * s1
+	MOVQ  CX, DX
+	IMULQ AX, DX
+	ADDQ  DX, BX
+
+	VPXOR    Y0, Y0, Y0 // zero
+	VPXOR    Y1, Y1, Y1 // vs1
+	VPXOR    Y2, Y2, Y2 // vs2
+	VPXOR    Y3, Y3, Y3 // vs3
+	VMOVDQU  weights<>+32(SB), Y4
+	VPCMPEQB Y5, Y5, Y5
+	VPSRLW   $15, Y5, Y5 // 16-bit ones
+
+loopV3:
+	VMOVDQU    (SI), Y6
+	VPADDD     Y1, Y3, Y3
+	VPSADBW    Y0, Y6, Y7
+	VPADDD     Y7, Y1, Y1
+	VPMADDUBSW Y4, Y6, Y7
+	VPMADDWD   Y5, Y7, Y7
+	VPADDD     Y7, Y2, Y2
+	ADDQ       $32, SI
+	SUBQ       $32, CX
+	JNZ        loopV3
+
+	// vs2 += 32 * vs3
+	VPSLLD $5, Y3, Y3
+	VPADDD Y3, Y2, Y2
+
+	// Add up the lanes of vs1 and vs2.
+	VEXTRACTI128 $1, Y1, X6
+	VPADDD       X6, X1, X1
+	VEXTRACTI128 $1, Y2, X7
+	VPADDD       X7, X2, X2
+	REDUCE
+
+// func updateArchV4(s1, s2 uint32, p []byte) (uint32, uint32)
+// Requires: AVX, AVX2, AVX512BW, AVX512F
+TEXT ·updateArchV4(SB), NOSPLIT, $0-40
+	MOVL s1+0(FP), AX
+	MOVL s2+4(FP), BX
+	MOVQ p_base+8(FP), SI
+	MOVQ p_len+16(FP), CX
+
+	// s2 += len(p) * s1
+	MOVQ  CX, DX
+	IMULQ AX, DX
+	ADDQ  DX, BX
+
+	VPXORD     Z0, Z0, Z0 // zero
+	VPXORD     Z1, Z1, Z1 // vs1
+	VPXORD     Z2, Z2, Z2 // vs2
+	VPXORD     Z3, Z3, Z3 // vs3
+	VMOVDQU64  weights<>+0(SB), Z4
+	VPTERNLOGD $0xff, Z5, Z5, Z5
+	VPSRLW     $15, Z5, Z5 // 16-bit ones
+
+loopV4:
+	VMOVDQU64  (SI), Z6
+	VPADDD     Z1, Z3, Z3
+	VPSADBW    Z0, Z6, Z7
+	VPADDD     Z7, Z1, Z1
+	VPMADDUBSW Z4, Z6, Z7
+	VPMADDWD   Z5, Z7, Z7
+	VPADDD     Z7, Z2, Z2
+	ADDQ       $64, SI
+	SUBQ       $64, CX
+	JNZ        loopV4
+
+	// vs2 += 64 * vs3
+	VPSLLD $6, Z3, Z3
+	VPADDD Z3, Z2, Z2
+
+	// Add up the lanes of vs1 and vs2.
+	VEXTRACTI64X4 $1, Z1, Y6
+	VPADDD        Y6, Y1, Y1
+	VEXTRACTI64X4 $1, Z2, Y7
+	VPADDD        Y7, Y2, Y2
+	VEXTRACTI128  $1, Y1, X6
+	VPADDD        X6, X1, X1
+	VEXTRACTI128  $1, Y2, X7
+	VPADDD        X7, X2, X2
+	REDUCE
@@ -0,0 +1,44 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build amd64 && !noasmtest
+// +build amd64,!noasmtest
+
+package adler32
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/intel/fastgo/internal/cpu"
+)
+
+// TestArchLevels runs every vector implementation the CPU supports, not only
+// the one update selects.
+func TestArchLevels(t *testing.T) {
+	data := make([]byte, chunkV3)
+	rand.New(rand.NewSource(4)).Read(data)
+	impls := []struct {
+		name  string
+		level int
+		block int
+		f     func(s1, s2 uint32, p []byte) (uint32, uint32)
+	}{
+		{"V3", 3, blockV3, updateArchV3},
+		{"V4", 4, blockV4, updateArchV4},
+	}
+	for _, impl := range impls {
+		if cpu.ArchLevel < impl.level {
+			t.Logf("skipping %s: ArchLevel is %d", impl.name, cpu.ArchLevel)
+			continue
+		}
+		for n := impl.block; n <= len(data); n += impl.block * 7 {
+			d := digest(0x1234<<16 | 0xabcd)
+			want := updateGeneric(d, data[:n])
+			s1, s2 := impl.f(uint32(d&0xffff), uint32(d>>16), data[:n])
+			if got := digest((s2%mod)<<16 | s1%mod); got != want {
+				t.Fatalf("%s(len %d) = %08x, want %08x", impl.name, n, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !amd64 || noasmtest
+// +build !amd64 noasmtest
+
+package adler32
+
+func update(d digest, p []byte) digest {
+	return updateGeneric(d, p)
+}
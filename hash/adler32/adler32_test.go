@@ -0,0 +1,89 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package adler32
+
+import (
+	"bytes"
+	"encoding"
+	"hash/adler32"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	data := make([]byte, 3*nmax+100)
+	rand.New(rand.NewSource(1)).Read(data)
+	ff := bytes.Repeat([]byte{0xff}, len(data)) // largest sums, checks for overflow
+	for _, in := range [][]byte{data, ff} {
+		for n := 0; n <= len(in); n = n*3/2 + 1 {
+			for _, off := range []int{0, 1, 31} {
+				if off > n {
+					continue
+				}
+				p := in[off:n]
+				if got, want := Checksum(p), adler32.Checksum(p); got != want {
+					t.Fatalf("Checksum(len %d, offset %d) = %08x, want %08x", len(p), off, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	data := make([]byte, 100000)
+	rand.New(rand.NewSource(2)).Read(data)
+	want := adler32.Checksum(data)
+	for _, step := range []int{1, 33, 64, 1000, nmax + 1} {
+		d := New()
+		for p := data; len(p) > 0; {
+			n := step
+			if n > len(p) {
+				n = len(p)
+			}
+			d.Write(p[:n])
+			p = p[n:]
+		}
+		if got := d.Sum32(); got != want {
+			t.Fatalf("step %d: Sum32 = %08x, want %08x", step, got, want)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	d, ref := New(), adler32.New()
+	d.Write([]byte("golden"))
+	ref.Write([]byte("golden"))
+	state, _ := d.(encoding.BinaryMarshaler).MarshalBinary()
+	refState, _ := ref.(encoding.BinaryMarshaler).MarshalBinary()
+	if !bytes.Equal(state, refState) {
+		t.Fatalf("MarshalBinary = %q, want %q", state, refState)
+	}
+	d2 := New()
+	if err := d2.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		t.Fatal(err)
+	}
+	if d2.Sum32() != d.Sum32() {
+		t.Fatalf("restored Sum32 = %08x, want %08x", d2.Sum32(), d.Sum32())
+	}
+}
+
+func BenchmarkChecksum(b *testing.B) {
+	data := make([]byte, 64*1024)
+	rand.New(rand.NewSource(3)).Read(data)
+	for _, size := range []int{64, 1024, 64 * 1024} {
+		b.Run("fastgo/"+strconv.Itoa(size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				Checksum(data[:size])
+			}
+		})
+		b.Run("std/"+strconv.Itoa(size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				adler32.Checksum(data[:size])
+			}
+		})
+	}
+}
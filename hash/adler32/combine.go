@@ -0,0 +1,32 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package adler32
+
+// Combine returns the Adler-32 checksum of the concatenation A||B, given the
+// checksum adler1 of A, the checksum adler2 of B and the length len2 of B.
+// It is the equivalent of zlib's adler32_combine. len2 must not be negative.
+func Combine(adler1, adler2 uint32, len2 int64) uint32 {
+	// With s1 and s2 the low and high halves of a checksum, appending B to
+	// A gives s1 = s1(A) + s1(B) - 1 and s2 = s2(A) + s2(B) + len2*(s1(A) - 1),
+	// all modulo mod. The -1 terms undo the initial value of s1 counted
+	// in adler2. Adding multiples of mod keeps every intermediate positive.
+	rem := uint32(len2 % mod)
+	sum1 := adler1 & 0xffff
+	sum2 := rem * sum1 % mod
+	sum1 += (adler2 & 0xffff) + mod - 1
+	sum2 += (adler1 >> 16) + (adler2 >> 16) + mod - rem
+	if sum1 >= mod {
+		sum1 -= mod
+	}
+	if sum1 >= mod {
+		sum1 -= mod
+	}
+	if sum2 >= mod<<1 {
+		sum2 -= mod << 1
+	}
+	if sum2 >= mod {
+		sum2 -= mod
+	}
+	return sum2<<16 | sum1
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package adler32
+
+import (
+	"hash/adler32"
+	"math/rand"
+	"testing"
+)
+
+func TestCombine(t *testing.T) {
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	for i := 0; i < 4096; i++ {
+		data[i] = 0xff // push the sums towards their upper bound
+	}
+	want := adler32.Checksum(data)
+	for _, split := range []int{0, 1, 7, 4096, mod, mod + 1, 65537, len(data) - 1, len(data)} {
+		a1 := adler32.Checksum(data[:split])
+		a2 := adler32.Checksum(data[split:])
+		if got := Combine(a1, a2, int64(len(data)-split)); got != want {
+			t.Fatalf("split %d: Combine = %08x, want %08x", split, got, want)
+		}
+	}
+}
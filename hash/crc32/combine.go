@@ -0,0 +1,101 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package crc32 complements the standard library hash/crc32 package with
+// functions that fastgo needs to assemble data processed in pieces, such as
+// combining the CRC-32 checksums of two adjacent blocks of data.
+package crc32
+
+import (
+	"hash/crc32"
+	"sync"
+)
+
+// Predefined polynomials, in the reversed notation used by hash/crc32.
+const (
+	IEEE       = crc32.IEEE
+	Castagnoli = crc32.Castagnoli
+	Koopman    = crc32.Koopman
+)
+
+// Combine returns the IEEE CRC-32 checksum of the concatenation A||B, given
+// the checksum crc1 of A, the checksum crc2 of B and the length len2 of B.
+// It is the equivalent of zlib's crc32_combine: at most one multiplication
+// modulo the polynomial per bit set in len2, with the powers of x it needs
+// computed once per polynomial.
+func Combine(crc1, crc2 uint32, len2 int64) uint32 {
+	return CombinePoly(IEEE, crc1, crc2, len2)
+}
+
+// CombinePoly is like Combine for checksums computed with the reversed
+// polynomial poly, as passed to hash/crc32.MakeTable.
+func CombinePoly(poly uint32, crc1, crc2 uint32, len2 int64) uint32 {
+	if len2 <= 0 {
+		return crc1
+	}
+	// The CRC of A followed by len2 zero bytes is crc1 times x^(8*len2)
+	// modulo P. Combined with crc2 it gives the CRC of A||B: the pre- and
+	// post-conditioning of both checksums cancel out in the XOR.
+	t := powers(poly)
+	p := uint32(1) << 31 // x^0
+	for k := 3; len2 != 0; k++ {
+		if len2&1 != 0 {
+			p = multModP(t[k], p, poly)
+		}
+		len2 >>= 1
+	}
+	return multModP(p, crc1, poly) ^ crc2
+}
+
+// powerTable holds x^(2^k) modulo a polynomial for k from 0 to 65, enough
+// for 8*len2 with any non-negative int64 len2.
+type powerTable [66]uint32
+
+var (
+	ieeePowers       = makePowers(IEEE)
+	castagnoliPowers = makePowers(Castagnoli)
+	otherPowers      sync.Map // poly uint32 -> *powerTable
+)
+
+// powers returns the table of powers of x modulo poly, computing it on
+// the first call for polynomials other than IEEE and Castagnoli.
+func powers(poly uint32) *powerTable {
+	switch poly {
+	case IEEE:
+		return ieeePowers
+	case Castagnoli:
+		return castagnoliPowers
+	}
+	if t, ok := otherPowers.Load(poly); ok {
+		return t.(*powerTable)
+	}
+	t, _ := otherPowers.LoadOrStore(poly, makePowers(poly))
+	return t.(*powerTable)
+}
+
+func makePowers(poly uint32) *powerTable {
+	t := new(powerTable)
+	t[0] = 1 << 30 // x^1
+	for k := 1; k < len(t); k++ {
+		t[k] = multModP(t[k-1], t[k-1], poly)
+	}
+	return t
+}
+
+// multModP returns a(x)*b(x) modulo the reversed polynomial poly. Bit 31
+// holds the coefficient of x^0, as in the CRC register.
+func multModP(a, b, poly uint32) uint32 {
+	var p uint32
+	for m := uint32(1) << 31; a != 0; m >>= 1 {
+		if a&m != 0 {
+			p ^= b
+			a ^= m
+		}
+		if b&1 != 0 {
+			b = b>>1 ^ poly
+		} else {
+			b >>= 1
+		}
+	}
+	return p
+}
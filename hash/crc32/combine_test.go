@@ -0,0 +1,53 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package crc32
+
+import (
+	"hash/crc32"
+	"math/rand"
+	"testing"
+)
+
+func TestCombine(t *testing.T) {
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	for _, poly := range []uint32{IEEE, Castagnoli, Koopman} {
+		tab := crc32.MakeTable(poly)
+		want := crc32.Checksum(data, tab)
+		for _, split := range []int{0, 1, 7, 4096, 65537, len(data) - 1, len(data)} {
+			crc1 := crc32.Checksum(data[:split], tab)
+			crc2 := crc32.Checksum(data[split:], tab)
+			if got := CombinePoly(poly, crc1, crc2, int64(len(data)-split)); got != want {
+				t.Fatalf("poly %08x split %d: CombinePoly = %08x, want %08x", poly, split, got, want)
+			}
+		}
+	}
+	a, b := []byte("hello, "), []byte("world")
+	got := Combine(crc32.ChecksumIEEE(a), crc32.ChecksumIEEE(b), int64(len(b)))
+	if want := crc32.ChecksumIEEE(append(a, b...)); got != want {
+		t.Fatalf("Combine = %08x, want %08x", got, want)
+	}
+}
+
+func BenchmarkCombine(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Combine(0x12345678, 0x9abcdef0, 1<<30)
+	}
+}
+
+// TestCombineLong checks lengths too large to checksum directly: combining
+// A, B and C must not depend on whether A||B or B||C is combined first.
+func TestCombineLong(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, poly := range []uint32{IEEE, Castagnoli, Koopman, 0xeb31d82e} {
+		for _, n := range [][2]int64{{1 << 20, 1<<30 + 7}, {1<<33 + 1, 1 << 40}, {1<<62 - 1, 1 << 62}} {
+			a, b, c := rnd.Uint32(), rnd.Uint32(), rnd.Uint32()
+			left := CombinePoly(poly, CombinePoly(poly, a, b, n[0]), c, n[1])
+			right := CombinePoly(poly, a, CombinePoly(poly, b, c, n[1]), n[0]+n[1])
+			if left != right {
+				t.Fatalf("poly %08x lengths %d: (A||B)||C = %08x, A||(B||C) = %08x", poly, n, left, right)
+			}
+		}
+	}
+}
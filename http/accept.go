@@ -0,0 +1,86 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package http
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Content codings supported by this package. "deflate" is the zlib format,
+// as specified by RFC 9110.
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+// negotiate returns the content coding to use for a response given the
+// Accept-Encoding header values of the request, or "" for none.
+//
+// Each coding gets the q-value of its own entry, or else that of the "*"
+// entry, if any. The supported coding with the highest non-zero q-value is
+// chosen, gzip winning ties. Malformed q-values count as zero.
+func negotiate(accept []string) string {
+	qGzip, qDeflate := qValues(accept)
+	switch {
+	case qGzip > 0 && qGzip >= qDeflate:
+		return encodingGzip
+	case qDeflate > 0:
+		return encodingDeflate
+	}
+	return ""
+}
+
+// acceptsGzip reports whether the Accept-Encoding header values allow a
+// gzip response, whatever the preferred coding.
+func acceptsGzip(accept []string) bool {
+	qGzip, _ := qValues(accept)
+	return qGzip > 0
+}
+
+// qValues returns the q-values of gzip and deflate in the Accept-Encoding
+// header values, or -1 for a coding that is not accepted at all.
+func qValues(accept []string) (qGzip, qDeflate float64) {
+	qGzip, qDeflate, qAny := -1.0, -1.0, -1.0
+	for _, value := range accept {
+		for _, entry := range strings.Split(value, ",") {
+			coding, q := parseCoding(entry)
+			switch coding {
+			case encodingGzip, "x-gzip":
+				qGzip = q
+			case encodingDeflate:
+				qDeflate = q
+			case "*":
+				qAny = q
+			}
+		}
+	}
+	if qGzip < 0 {
+		qGzip = qAny
+	}
+	if qDeflate < 0 {
+		qDeflate = qAny
+	}
+	return qGzip, qDeflate
+}
+
+// parseCoding parses one entry of an Accept-Encoding list, such as
+// "gzip;q=0.8", into a lower-cased coding and its q-value.
+func parseCoding(entry string) (coding string, q float64) {
+	q = 1
+	params := strings.Split(entry, ";")
+	coding = strings.ToLower(strings.TrimSpace(params[0]))
+	for _, p := range params[1:] {
+		p = strings.TrimSpace(p)
+		if len(p) < 2 || (p[0] != 'q' && p[0] != 'Q') || p[1] != '=' {
+			continue
+		}
+		v, err := strconv.ParseFloat(p[2:], 64)
+		if err != nil || v < 0 || v > 1 {
+			v = 0
+		}
+		q = v
+	}
+	return coding, q
+}
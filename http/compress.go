@@ -0,0 +1,374 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package http provides net/http middleware, a client transport and a file
+// server that compress and decompress HTTP bodies with the Intel-optimized
+// fastgo gzip and zlib packages.
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/intel/fastgo/compress/gzip"
+	"github.com/intel/fastgo/compress/zlib"
+)
+
+// DefaultMinSize is the default value of Compressor.MinSize.
+const DefaultMinSize = 1024
+
+// compressedTypes lists the media types and type prefixes of content that
+// is compressed already.
+var compressedTypes = []string{
+	"image/", "video/", "audio/", "font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip",
+	"application/x-bzip2", "application/x-xz", "application/zstd",
+	"application/x-7z-compressed", "application/x-rar-compressed",
+	"application/vnd.rar",
+}
+
+// DefaultSkip reports whether content of the given Content-Type is already
+// compressed and gains nothing from compression. It is true for images
+// other than SVG, audio, video, WOFF fonts and common archive formats.
+func DefaultSkip(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if mediaType == "image/svg+xml" {
+		return false
+	}
+	for _, t := range compressedTypes {
+		if strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t) || mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// encoder is the common interface of the gzip and zlib writers.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// A Compressor compresses the responses of HTTP handlers with gzip or zlib,
+// according to the Accept-Encoding header of each request. Writers are
+// pooled and reused through Reset, so compressing a response allocates
+// little once the pools are warm.
+//
+// The exported fields must not be changed once the Compressor is in use.
+type Compressor struct {
+	// MinSize is the size in bytes under which a response body is sent
+	// uncompressed. The start of the body is buffered until MinSize bytes
+	// have been written, the handler returns, or it flushes.
+	MinSize int
+
+	// Skip reports whether responses of a Content-Type should be sent
+	// uncompressed. If nil, DefaultSkip is used.
+	Skip func(contentType string) bool
+
+	level    int
+	gzipPool sync.Pool
+	zlibPool sync.Pool
+}
+
+// NewCompressor returns a Compressor using the given compression level,
+// with MinSize set to DefaultMinSize. The level can be any value accepted
+// by gzip.NewWriterLevel; BestSpeed, 2 and HuffmanOnly are the fastest.
+func NewCompressor(level int) (*Compressor, error) {
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		return nil, fmt.Errorf("http: invalid compression level: %d", level)
+	}
+	return &Compressor{MinSize: DefaultMinSize, level: level}, nil
+}
+
+var defaultCompressor, _ = NewCompressor(gzip.DefaultCompression)
+
+// Handler wraps h so that its responses are compressed by a Compressor
+// using the default compression level.
+func Handler(h http.Handler) http.Handler {
+	return defaultCompressor.Handler(h)
+}
+
+// Handler wraps h so that its responses are compressed when the client
+// accepts gzip or deflate and the response is at least MinSize bytes long,
+// has no Content-Encoding, is not marked "Cache-Control: no-transform", and
+// its Content-Type is not skipped.
+//
+// Compressed responses have their Content-Length and Accept-Ranges removed.
+// Responses sent uncompressed because they are short get a Content-Length.
+// Every response gets "Vary: Accept-Encoding". Calls to Flush compress and
+// send all data written so far.
+//
+// A HEAD request gets the headers of the matching GET: its response is
+// marked compressed when the body the handler writes, or the Content-Length
+// it sets, is at least MinSize bytes long, and the body is dropped. The
+// wrapped ResponseWriter implements http.Hijacker if the original one does,
+// so that handlers can take over the connection for a WebSocket upgrade.
+func (c *Compressor) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addVary(w.Header(), "Accept-Encoding")
+		encoding := negotiate(r.Header.Values("Accept-Encoding"))
+		if encoding == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		cw := &responseWriter{ResponseWriter: w, c: c, encoding: encoding, head: r.Method == http.MethodHead}
+		defer cw.finish()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// addVary adds value to the Vary header of h unless it is listed already.
+func addVary(h http.Header, value string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f == "*" || strings.EqualFold(f, value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
+
+func (c *Compressor) skip(contentType string) bool {
+	if c.Skip != nil {
+		return c.Skip(contentType)
+	}
+	return DefaultSkip(contentType)
+}
+
+func (c *Compressor) getEncoder(encoding string, w io.Writer) encoder {
+	pool := &c.gzipPool
+	if encoding == encodingDeflate {
+		pool = &c.zlibPool
+	}
+	if e, ok := pool.Get().(encoder); ok {
+		e.Reset(w)
+		return e
+	}
+	// The level was checked by NewCompressor.
+	if encoding == encodingDeflate {
+		z, _ := zlib.NewWriterLevel(w, c.level)
+		return z
+	}
+	z, _ := gzip.NewWriterLevel(w, c.level)
+	return z
+}
+
+func (c *Compressor) putEncoder(encoding string, e encoder) {
+	e.Reset(io.Discard) // drop the reference to the response
+	if encoding == encodingDeflate {
+		c.zlibPool.Put(e)
+	} else {
+		c.gzipPool.Put(e)
+	}
+}
+
+// responseWriter buffers the start of a response until it can decide
+// whether to compress it.
+type responseWriter struct {
+	http.ResponseWriter
+	c        *Compressor
+	encoding string
+	head     bool // the request is a HEAD, whose body is dropped
+
+	status   int    // status set by the handler, sent with the headers
+	buf      []byte // body buffered before the decision
+	decided  bool   // headers have been sent
+	enc      encoder
+	discard  bool // the response to a HEAD is marked compressed
+	hijacked bool
+	err      error
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	if status < 200 {
+		// Informational responses go out as they are.
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		w.start(false, nil)
+	}
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		if n, ok := w.contentLength(); ok && n < w.c.MinSize {
+			w.start(false, p)
+		}
+	}
+	if !w.decided {
+		if !w.compressible() {
+			w.start(false, p)
+		} else if len(w.buf)+len(p) < w.c.MinSize {
+			w.buf = append(w.buf, p...)
+			return len(p), nil
+		} else {
+			w.start(true, p)
+		}
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.discard {
+		return len(p), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// contentLength returns the Content-Length set by the handler, if any.
+func (w *responseWriter) contentLength() (int, bool) {
+	cl := w.Header().Get("Content-Length")
+	if cl == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(cl)
+	return n, err == nil
+}
+
+// compressible reports whether the status and headers set so far allow
+// compression. Partial content is sent as it is, as its Content-Range
+// refers to the uncompressed representation.
+func (w *responseWriter) compressible() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if w.status == http.StatusPartialContent || h.Get("Content-Range") != "" {
+		return false
+	}
+	for _, v := range h.Values("Cache-Control") {
+		if strings.Contains(strings.ToLower(v), "no-transform") {
+			return false
+		}
+	}
+	ct := h.Get("Content-Type")
+	return ct == "" || !w.c.skip(ct)
+}
+
+// sniffLen is the amount of data http.DetectContentType looks at.
+const sniffLen = 512
+
+// start sends the headers and the buffered body, compressed if compress is
+// set, and makes further writes go straight through. next is the data about
+// to be written, used with the buffered body to sniff the Content-Type.
+func (w *responseWriter) start(compress bool, next []byte) {
+	w.decided = true
+	h := w.Header()
+	if _, ok := h["Content-Type"]; !ok && len(w.buf)+len(next) > 0 {
+		// Sniff from the uncompressed data, as net/http would.
+		sniff := w.buf
+		if n := sniffLen - len(sniff); n > 0 && len(next) > 0 {
+			if n > len(next) {
+				n = len(next)
+			}
+			sniff = append(sniff[:len(sniff):len(sniff)], next[:n]...)
+		}
+		h.Set("Content-Type", http.DetectContentType(sniff))
+		if compress && w.c.skip(h.Get("Content-Type")) {
+			compress = false
+		}
+	}
+	if compress {
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		h.Set("Content-Encoding", w.encoding)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if compress && w.head {
+		w.discard = true
+	} else if compress {
+		w.enc = w.c.getEncoder(w.encoding, w.ResponseWriter)
+	}
+	if len(w.buf) > 0 && !w.discard {
+		if w.enc != nil {
+			_, w.err = w.enc.Write(w.buf)
+		} else {
+			_, w.err = w.ResponseWriter.Write(w.buf)
+		}
+	}
+	w.buf = nil
+}
+
+// Flush sends the data written so far to the client. An undecided response
+// is compressed if its headers allow it, however short it is so far.
+func (w *responseWriter) Flush() {
+	if !w.decided {
+		w.start(w.compressible(), nil)
+	}
+	if w.enc != nil && w.err == nil {
+		w.err = w.enc.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack hands the connection over to the handler, which then writes the
+// response itself, uncompressed. It returns http.ErrNotSupported if the
+// underlying ResponseWriter is not an http.Hijacker.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// finish ends the response once the handler has returned.
+func (w *responseWriter) finish() {
+	if w.hijacked {
+		return
+	}
+	if !w.decided && w.head && w.compressible() {
+		// A GET would write the body the Content-Length announces.
+		if n, ok := w.contentLength(); ok && n >= w.c.MinSize {
+			w.start(true, nil)
+		}
+	}
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			// Nothing was written; let net/http send its default response.
+			return
+		}
+		if _, ok := w.Header()["Content-Length"]; !ok {
+			w.Header().Set("Content-Length", strconv.Itoa(len(w.buf)))
+		}
+		w.start(false, nil)
+	}
+	if w.enc != nil {
+		if err := w.enc.Close(); err == nil {
+			w.c.putEncoder(w.encoding, w.enc)
+		}
+		w.enc = nil
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intel/fastgo/compress/gzip"
+	"github.com/intel/fastgo/compress/zlib"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"gzip, deflate, br", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"GZIP;Q=0.9, deflate;q=0.8", "gzip"},
+		{"gzip;q=0", ""},
+		{"gzip;q=0, *", "deflate"},
+		{"*;q=0.1", "gzip"},
+		{"*, gzip;q=0, deflate;q=0", ""},
+		{"br, identity", ""},
+		{"x-gzip", "gzip"},
+		{"gzip;q=abc", ""},
+	} {
+		if got := negotiate([]string{tc.accept}); got != tc.want {
+			t.Errorf("negotiate(%q) = %q, want %q", tc.accept, got, tc.want)
+		}
+	}
+}
+
+var bigBody = strings.Repeat("<p>fastgo compresses this paragraph.</p>\n", 200)
+
+func serve(h http.Handler, method, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/", nil)
+	if accept != "" {
+		req.Header.Set("Accept-Encoding", accept)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var r io.Reader = rec.Body
+	var err error
+	switch rec.Header().Get("Content-Encoding") {
+	case "gzip":
+		r, err = gzip.NewReader(rec.Body)
+	case "deflate":
+		r, err = zlib.NewReader(rec.Body)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestHandler(t *testing.T) {
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "999999") // wrong once compressed
+		w.Header().Set("Accept-Ranges", "bytes")
+		io.WriteString(w, bigBody[:100])
+		io.WriteString(w, bigBody[100:])
+	}))
+	for i := 0; i < 3; i++ { // reuses pooled writers
+		for _, enc := range []string{"gzip", "deflate"} {
+			rec := serve(h, "GET", enc)
+			if got := rec.Header().Get("Content-Encoding"); got != enc {
+				t.Fatalf("Content-Encoding = %q, want %q", got, enc)
+			}
+			if rec.Header().Get("Content-Length") != "" || rec.Header().Get("Accept-Ranges") != "" {
+				t.Fatalf("compressed response kept headers: %v", rec.Header())
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Fatalf("Vary = %q", got)
+			}
+			if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+				t.Fatalf("Content-Type = %q, want sniffed text/html", got)
+			}
+			if rec.Body.Len() >= len(bigBody) {
+				t.Fatalf("body not compressed: %d bytes", rec.Body.Len())
+			}
+			if got := decode(t, rec); got != bigBody {
+				t.Fatalf("decoded body differs")
+			}
+		}
+	}
+
+	rec := serve(h, "GET", "")
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != bigBody {
+		t.Fatalf("no Accept-Encoding: got encoding %q", rec.Header().Get("Content-Encoding"))
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Fatalf("uncompressed Vary = %q", got)
+	}
+	if rec := serve(h, "HEAD", "gzip"); rec.Header().Get("Content-Encoding") != "gzip" || rec.Body.Len() != 0 {
+		t.Fatalf("HEAD: headers %v, %d body bytes", rec.Header(), rec.Body.Len())
+	}
+}
+
+// TestHandlerHead checks that HEAD responses have the headers of the
+// matching GET, whether the handler writes the body or only sets a
+// Content-Length.
+func TestHandlerHead(t *testing.T) {
+	for _, content := range []string{bigBody, "short"} {
+		h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.ServeContent(w, r, "page.html", time.Time{}, strings.NewReader(content))
+		}))
+		get, head := serve(h, "GET", "gzip"), serve(h, "HEAD", "gzip")
+		if head.Body.Len() != 0 {
+			t.Fatalf("%d bytes: HEAD response has a body", len(content))
+		}
+		for _, k := range []string{"Content-Encoding", "Content-Length", "Content-Type", "Vary"} {
+			if g, h := get.Header().Get(k), head.Header().Get(k); g != h {
+				t.Fatalf("%d bytes: %s is %q for GET, %q for HEAD", len(content), k, g, h)
+			}
+		}
+	}
+}
+
+// TestHandlerHijack checks that a handler behind the Compressor can take
+// over the connection, as for a WebSocket upgrade.
+func TestHandlerHijack(t *testing.T) {
+	srv := httptest.NewServer(Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			t.Error("ResponseWriter does not implement http.Hijacker")
+			return
+		}
+		conn, rw, err := hj.Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+		line, _ := rw.ReadString('\n')
+		rw.WriteString(line)
+		rw.Flush()
+	})))
+	defer srv.Close()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: x\r\nAccept-Encoding: gzip\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("upgrade: %v, %v", resp, err)
+	}
+	io.WriteString(conn, "ping\n")
+	if line, err := br.ReadString('\n'); err != nil || line != "ping\n" {
+		t.Fatalf("echo = %q, %v", line, err)
+	}
+}
+
+func TestHandlerSkips(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		header map[string]string
+		body   string
+	}{
+		{"small", nil, "short"},
+		{"image", map[string]string{"Content-Type": "image/png"}, bigBody},
+		{"sniffed", nil, "\x1f\x8b\x08" + bigBody}, // sniffed as application/x-gzip
+		{"encoded", map[string]string{"Content-Encoding": "br"}, bigBody},
+		{"no-transform", map[string]string{"Cache-Control": "public, no-transform"}, bigBody},
+	} {
+		h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for k, v := range tc.header {
+				w.Header().Set(k, v)
+			}
+			io.WriteString(w, tc.body)
+		}))
+		rec := serve(h, "GET", "gzip")
+		if got := rec.Header().Get("Content-Encoding"); got != tc.header["Content-Encoding"] {
+			t.Fatalf("%s: Content-Encoding = %q", tc.name, got)
+		}
+		if rec.Body.String() != tc.body {
+			t.Fatalf("%s: body changed", tc.name)
+		}
+		if tc.name == "small" && rec.Header().Get("Content-Length") != "5" {
+			t.Fatalf("small: Content-Length = %q, want 5", rec.Header().Get("Content-Length"))
+		}
+	}
+}
+
+func TestHandlerFlush(t *testing.T) {
+	flushed := make(chan string, 1)
+	var rec *httptest.ResponseRecorder
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		// The flushed bytes decode to everything written so far.
+		z, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+		if err != nil {
+			flushed <- err.Error()
+			return
+		}
+		b := make([]byte, 100)
+		n, _ := io.ReadAtLeast(z, b, len("data: first\n\n"))
+		flushed <- string(b[:n])
+		io.WriteString(w, "data: second\n\n")
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if got := <-flushed; got != "data: first\n\n" {
+		t.Fatalf("after Flush, client can read %q", got)
+	}
+	if !rec.Flushed || rec.Code != http.StatusAccepted {
+		t.Fatalf("Flushed = %v, Code = %d", rec.Flushed, rec.Code)
+	}
+	if got := decode(t, rec); got != "data: first\n\ndata: second\n\n" {
+		t.Fatalf("body = %q", got)
+	}
+}
+
+func TestHandlerRange(t *testing.T) {
+	content := strings.Repeat("0123456789", 1200)
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "data.txt", time.Time{}, strings.NewReader(content))
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=0-4999")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPartialContent || rec.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("range: code %d, Content-Encoding %q", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("Content-Range") != "bytes 0-4999/12000" || rec.Body.String() != content[:5000] {
+		t.Fatalf("range: Content-Range %q, %d body bytes", rec.Header().Get("Content-Range"), rec.Body.Len())
+	}
+}
+
+func TestHandlerNoBody(t *testing.T) {
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	rec := serve(h, "GET", "gzip")
+	if rec.Code != http.StatusNotModified || rec.Header().Get("Content-Encoding") != "" || rec.Body.Len() != 0 {
+		t.Fatalf("304: code %d, headers %v, %d body bytes", rec.Code, rec.Header(), rec.Body.Len())
+	}
+}
+
+func TestNewCompressor(t *testing.T) {
+	if _, err := NewCompressor(42); err == nil {
+		t.Fatal("NewCompressor(42) succeeded")
+	}
+	c, err := NewCompressor(gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.MinSize = 0
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "tiny")
+	}))
+	rec := serve(h, "GET", "deflate")
+	if rec.Header().Get("Content-Encoding") != "deflate" || decode(t, rec) != "tiny" {
+		t.Fatalf("MinSize 0: headers %v", rec.Header())
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package http
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/intel/fastgo/compress/flate"
+	"github.com/intel/fastgo/compress/gzip"
+	"github.com/intel/fastgo/compress/zlib"
+	"github.com/intel/fastgo/internal/iocount"
+)
+
+// Default limits of a Decompressor.
+const (
+	DefaultMaxSize  = 32 << 20 // 32 MB
+	DefaultMaxRatio = 100
+)
+
+// ratioSlack is the decompressed size under which the ratio limit is not
+// enforced, as tiny bodies of repeated bytes legitimately expand a lot.
+const ratioSlack = 64 << 10
+
+var (
+	// ErrBodyTooLarge is returned by the Read method of a decompressed
+	// request body larger than the Decompressor's MaxSize.
+	ErrBodyTooLarge = errors.New("http: decompressed request body too large")
+
+	// ErrBodyExpansion is returned by the Read method of a request body
+	// that expands more than the Decompressor's MaxRatio.
+	ErrBodyExpansion = errors.New("http: request body expands too much")
+)
+
+// A Decompressor decompresses the bodies of requests sent with a
+// Content-Encoding of gzip or deflate before passing them to handlers.
+// Decoders are pooled and reused through Reset.
+//
+// The exported fields must not be changed once the Decompressor is in use.
+type Decompressor struct {
+	// MaxSize is the largest decompressed body accepted, in bytes.
+	// Zero means no limit.
+	MaxSize int64
+
+	// MaxRatio is the largest accepted ratio of the decompressed size to
+	// the compressed size. It protects against decompression bombs and is
+	// only enforced once 64 KB have been decompressed. Zero means no limit.
+	MaxRatio int64
+
+	bodies sync.Pool
+}
+
+// NewDecompressor returns a Decompressor with the default limits.
+func NewDecompressor() *Decompressor {
+	return &Decompressor{MaxSize: DefaultMaxSize, MaxRatio: DefaultMaxRatio}
+}
+
+var defaultDecompressor = NewDecompressor()
+
+// DecompressHandler wraps h so that compressed request bodies are
+// decompressed by a Decompressor with the default limits.
+func DecompressHandler(h http.Handler) http.Handler {
+	return defaultDecompressor.Handler(h)
+}
+
+// Handler wraps h so that the body of a request with a Content-Encoding of
+// gzip, x-gzip or deflate is replaced by a reader of the decompressed data.
+// The Content-Encoding and Content-Length headers are removed and
+// r.ContentLength is set to -1. "deflate" bodies are accepted both in the
+// zlib format of RFC 9110 and, as some clients send them, as raw DEFLATE.
+// Requests with any other coding than identity get 415 Unsupported Media
+// Type.
+//
+// If the compressed data is corrupt, Read on the body fails and the
+// response is replaced by 400 Bad Request, unless the handler has already
+// sent its headers. Bodies exceeding MaxSize or MaxRatio fail with
+// ErrBodyTooLarge or ErrBodyExpansion and get 413 Request Entity Too Large.
+//
+// The body must not be used once the handler has returned.
+func (d *Decompressor) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		coding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		switch coding {
+		case "", "identity":
+			h.ServeHTTP(w, r)
+			return
+		case encodingGzip, "x-gzip", encodingDeflate:
+		default:
+			http.Error(w, "unsupported Content-Encoding", http.StatusUnsupportedMediaType)
+			return
+		}
+		b := d.getBody()
+		defer d.putBody(b)
+		if err := b.reset(r.Body, coding); err != nil {
+			if err != io.EOF {
+				http.Error(w, "invalid "+coding+" request body", http.StatusBadRequest)
+				return
+			}
+			b.empty = true
+		}
+
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.Header = r.Header.Clone()
+		r2.Header.Del("Content-Encoding")
+		r2.Header.Del("Content-Length")
+		r2.ContentLength = -1
+		r2.Body = b
+		if b.empty {
+			r2.Body = http.NoBody
+			r2.ContentLength = 0
+		}
+		gw := &guardWriter{ResponseWriter: w, body: b}
+		h.ServeHTTP(gw, r2)
+		if !gw.wroteHeader && b.status() != 0 {
+			gw.reject()
+		}
+	})
+}
+
+func (d *Decompressor) getBody() *body {
+	if b, ok := d.bodies.Get().(*body); ok {
+		return b
+	}
+	b := &body{d: d}
+	b.br = bufio.NewReader(&b.src)
+	return b
+}
+
+func (d *Decompressor) putBody(b *body) {
+	b.src.R = nil
+	b.orig = nil
+	b.br.Reset(&b.src)
+	d.bodies.Put(b)
+}
+
+// body is a decompressed request body. Its decoders are kept when it goes
+// back to the pool.
+type body struct {
+	d     *Decompressor
+	orig  io.ReadCloser
+	src   iocount.Reader // compressed bytes read from orig
+	br    *bufio.Reader
+	gz    *gzip.Reader
+	zr    io.ReadCloser // zlib decoder
+	fr    io.ReadCloser // raw DEFLATE decoder
+	r     io.Reader     // decoder in use
+	n     int64         // decompressed bytes returned
+	err   error         // sticky decoding error
+	empty bool          // the compressed body is empty
+}
+
+// reset prepares b to decode orig, compressed with coding. It returns
+// io.EOF if orig is empty.
+func (b *body) reset(orig io.ReadCloser, coding string) (err error) {
+	b.orig, b.n, b.err, b.empty = orig, 0, nil, false
+	b.src = iocount.Reader{R: orig}
+	b.br.Reset(&b.src)
+	if coding != encodingDeflate {
+		if b.gz == nil {
+			b.gz, err = gzip.NewReader(b.br)
+		} else {
+			err = b.gz.Reset(b.br)
+		}
+		b.r = b.gz
+		return err
+	}
+	hdr, err := b.br.Peek(2)
+	if len(hdr) == 0 {
+		return err
+	}
+	if len(hdr) == 2 && hdr[0]&0x0f == 8 && hdr[0]>>4 <= 7 && (uint(hdr[0])<<8|uint(hdr[1]))%31 == 0 {
+		if b.zr == nil {
+			b.zr, err = zlib.NewReader(b.br)
+		} else {
+			err = b.zr.(zlib.Resetter).Reset(b.br, nil)
+		}
+		b.r = b.zr
+		return err
+	}
+	if b.fr == nil {
+		b.fr = flate.NewReader(b.br)
+	} else {
+		err = b.fr.(flate.Resetter).Reset(b.br, nil)
+	}
+	b.r = b.fr
+	return err
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	if max := b.d.MaxSize; max > 0 && b.n > max {
+		n -= int(b.n - max)
+		b.n = max
+		err = ErrBodyTooLarge
+	} else if ratio := b.d.MaxRatio; ratio > 0 && b.n > ratioSlack && b.n > ratio*b.src.N {
+		err = ErrBodyExpansion
+	}
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *body) Close() error {
+	return b.orig.Close()
+}
+
+// status returns the response status for the error met reading b, or 0 if
+// there was none or it was not caused by the request.
+func (b *body) status() int {
+	switch b.err {
+	case nil:
+		return 0
+	case ErrBodyTooLarge, ErrBodyExpansion:
+		return http.StatusRequestEntityTooLarge
+	case gzip.ErrHeader, gzip.ErrChecksum, zlib.ErrHeader, zlib.ErrChecksum, zlib.ErrDictionary, io.ErrUnexpectedEOF:
+		return http.StatusBadRequest
+	}
+	if _, ok := b.err.(flate.CorruptInputError); ok {
+		return http.StatusBadRequest
+	}
+	return 0
+}
+
+// guardWriter replaces the response with an error status if the request
+// body turned out to be invalid before the handler sent its headers.
+type guardWriter struct {
+	http.ResponseWriter
+	body        *body
+	wroteHeader bool
+	rejected    bool
+}
+
+func (w *guardWriter) reject() {
+	w.wroteHeader = true
+	w.rejected = true
+	code := w.body.status()
+	w.Header().Del("Content-Length")
+	http.Error(w.ResponseWriter, w.body.err.Error(), code)
+}
+
+func (w *guardWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	if code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.body.status() != 0 {
+		w.reject()
+		return
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *guardWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.rejected {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *guardWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && !w.rejected {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *guardWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package http
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/intel/fastgo/compress/flate"
+	"github.com/intel/fastgo/compress/gzip"
+	"github.com/intel/fastgo/compress/zlib"
+)
+
+func compressBody(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw":
+		w, _ = flate.NewWriter(&buf, flate.BestSpeed)
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+// echo answers with the request body it reads, or 500 if reading fails.
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Encoding") != "" {
+		http.Error(w, "headers not updated", http.StatusInternalServerError)
+		return
+	}
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+})
+
+func post(h http.Handler, coding string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+	if coding != "" {
+		req.Header.Set("Content-Encoding", coding)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDecompressHandler(t *testing.T) {
+	data := []byte(strings.Repeat(`{"id": 1, "name": "fastgo"}`, 1000))
+	h := DecompressHandler(echo)
+	for i := 0; i < 3; i++ { // reuses pooled decoders
+		for _, tc := range []struct{ coding, format string }{
+			{"gzip", "gzip"}, {"x-gzip", "gzip"}, {"deflate", "deflate"}, {"deflate", "raw"}, {"", ""},
+		} {
+			body := data
+			if tc.format != "" {
+				body = compressBody(t, tc.format, data)
+			}
+			rec := post(h, tc.coding, body)
+			if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), data) {
+				t.Fatalf("%s as %s: status %d, %d bytes", tc.format, tc.coding, rec.Code, rec.Body.Len())
+			}
+		}
+	}
+
+	if rec := post(h, "gzip", nil); rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Fatalf("empty body: status %d", rec.Code)
+	}
+	if rec := post(h, "br", data); rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("br: status %d, want %d", rec.Code, http.StatusUnsupportedMediaType)
+	}
+}
+
+func TestDecompressHandlerErrors(t *testing.T) {
+	data := make([]byte, 200000)
+	rand.New(rand.NewSource(1)).Read(data)
+	gz := compressBody(t, "gzip", data)
+	corrupt := append([]byte(nil), gz...)
+	corrupt[len(corrupt)-6] ^= 0xff // CRC-32
+	bomb := compressBody(t, "gzip", make([]byte, 1<<20))
+
+	d := NewDecompressor()
+	h := d.Handler(echo)
+	for _, tc := range []struct {
+		name string
+		body []byte
+		want int
+	}{
+		{"bad header", []byte("not gzip at all"), http.StatusBadRequest},
+		{"truncated", gz[:len(gz)/2], http.StatusBadRequest},
+		{"checksum", corrupt, http.StatusBadRequest},
+		{"ratio", bomb, http.StatusRequestEntityTooLarge},
+	} {
+		if rec := post(h, "gzip", tc.body); rec.Code != tc.want {
+			t.Fatalf("%s: status %d, want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+
+	d = &Decompressor{MaxSize: 1000, MaxRatio: DefaultMaxRatio}
+	if rec := post(d.Handler(echo), "gzip", gz); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("size: status %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	d = &Decompressor{}
+	if rec := post(d.Handler(echo), "gzip", bomb); rec.Code != http.StatusOK || rec.Body.Len() != 1<<20 {
+		t.Fatalf("no limits: status %d, %d bytes", rec.Code, rec.Body.Len())
+	}
+
+	// A handler that has already answered keeps its response.
+	early := d.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		io.Copy(io.Discard, r.Body)
+	}))
+	if rec := post(early, "gzip", corrupt); rec.Code != http.StatusAccepted {
+		t.Fatalf("early response: status %d, want %d", rec.Code, http.StatusAccepted)
+	}
+}
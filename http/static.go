@@ -0,0 +1,532 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intel/fastgo/compress/gzip"
+)
+
+// A FileServer serves the files of an fs.FS, sending them gzip compressed
+// to clients that accept it.
+//
+// The compressed variant of a file is its ".gz" sibling when there is one,
+// as written by Precompress, served as it is. The sibling is decompressed
+// once, and again whenever its size or modification time changes, to check
+// that it holds the content of the file; a stale sibling is ignored.
+// Otherwise the file is compressed on the first request and the result is
+// cached, in memory or in CacheDir, until the modification time or size of
+// the file changes. Each variant gets its own ETag, derived from the content
+// of the file and, for a .gz sibling, from the size and modification time of
+// the sibling, so that If-None-Match works for both. Range and conditional
+// requests are handled by http.ServeContent.
+//
+// Only the files that may be compressed are read whole, and then in a
+// stream: the others are hashed for their ETag without being kept.
+//
+// The FileServer remembers up to 4096 files, and up to 64 MB of compressed
+// variants in memory. Past these limits it forgets arbitrary files, which
+// are hashed, and compressed, again on their next request.
+//
+// Directories are served by http.FileServer, but index.html files are
+// compressed like other files.
+//
+// The exported fields must not be changed once the FileServer is in use.
+type FileServer struct {
+	// MinSize is the size in bytes under which files are sent
+	// uncompressed.
+	MinSize int
+
+	// Skip reports whether files of a Content-Type should be sent
+	// uncompressed. If nil, DefaultSkip is used.
+	Skip func(contentType string) bool
+
+	// CacheDir is a directory where compressed variants are cached. If
+	// empty, they are kept in memory. Cached files are named after the
+	// content they hold, so they can be shared by several servers and
+	// survive restarts. The directory is never pruned.
+	CacheDir string
+
+	fsys  fs.FS
+	level int
+	files http.Handler
+
+	mu         sync.Mutex
+	cache      map[string]*fileEntry
+	cacheBytes int // size of the variants in memory of the loaded entries
+	maxEntries int
+	maxBytes   int
+}
+
+// Default limits of the cache of a FileServer.
+const (
+	maxCacheEntries = 4096
+	maxCacheBytes   = 64 << 20
+)
+
+// NewFileServer returns a FileServer for fsys compressing at the given
+// level, with MinSize set to DefaultMinSize. The level can be any value
+// accepted by gzip.NewWriterLevel.
+func NewFileServer(fsys fs.FS, level int) (*FileServer, error) {
+	return newFileServer(fsys, http.FileServer(http.FS(fsys)), level)
+}
+
+// NewFileSystemServer is like NewFileServer, but serves an http.FileSystem,
+// such as an http.Dir.
+func NewFileSystemServer(hfs http.FileSystem, level int) (*FileServer, error) {
+	return newFileServer(fileSystemFS{hfs}, http.FileServer(hfs), level)
+}
+
+func newFileServer(fsys fs.FS, files http.Handler, level int) (*FileServer, error) {
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		return nil, fmt.Errorf("http: invalid compression level: %d", level)
+	}
+	return &FileServer{
+		MinSize:    DefaultMinSize,
+		fsys:       fsys,
+		level:      level,
+		files:      files,
+		cache:      make(map[string]*fileEntry),
+		maxEntries: maxCacheEntries,
+		maxBytes:   maxCacheBytes,
+	}, nil
+}
+
+// fileSystemFS makes an http.FileSystem an fs.FS. The files it opens are
+// http.Files, which are fs.Files too.
+type fileSystemFS struct {
+	hfs http.FileSystem
+}
+
+func (f fileSystemFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.hfs.Open("/" + name)
+}
+
+// fileEntry describes a served file and its compressed variant.
+type fileEntry struct {
+	modTime time.Time
+	size    int64
+
+	once    sync.Once
+	err     error
+	ctype   string // Content-Type of the uncompressed file
+	hash    string // hex digest of the uncompressed file
+	sibling bool   // a .gz sibling matched the file when it was loaded
+	gz      []byte // compressed variant cached in memory
+	gzPath  string // compressed variant cached on disk
+
+	// Guarded by FileServer.mu.
+	loaded bool     // counted in FileServer.cacheBytes
+	sib    sibState // last .gz sibling checked
+}
+
+// sibState records whether the .gz sibling of a given size and modification
+// time holds the content of its file.
+type sibState struct {
+	checked bool
+	size    int64
+	modTime time.Time
+	ok      bool
+}
+
+// compressed reports whether e has a compressed variant.
+func (e *fileEntry) compressed() bool {
+	return e.sibling || e.gz != nil || e.gzPath != ""
+}
+
+// ServeHTTP implements http.Handler.
+func (s *FileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upath := r.URL.Path
+	if !strings.HasPrefix(upath, "/") {
+		upath = "/" + upath
+	}
+	name := strings.TrimPrefix(path.Clean(upath), "/")
+	if strings.HasSuffix(upath, "/") {
+		name = path.Join(name, "index.html")
+	} else if strings.HasSuffix(upath, "/index.html") {
+		// http.FileServer redirects to the directory.
+		s.files.ServeHTTP(w, r)
+		return
+	}
+
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		s.files.ServeHTTP(w, r)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	rs, seekable := f.(io.ReadSeeker)
+	if err != nil || !fi.Mode().IsRegular() || !seekable {
+		s.files.ServeHTTP(w, r)
+		return
+	}
+	e, err := s.entry(name, fi)
+	if err != nil {
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", e.ctype)
+	if e.compressed() {
+		addVary(h, "Accept-Encoding")
+		if acceptsGzip(r.Header.Values("Accept-Encoding")) {
+			if gz, etag := s.openVariant(name, e); gz != nil {
+				defer gz.Close()
+				h.Set("Content-Encoding", encodingGzip)
+				h.Set("ETag", `"`+etag+`"`)
+				http.ServeContent(w, r, name, fi.ModTime(), gz)
+				return
+			}
+		}
+	}
+	h.Set("ETag", `"`+e.hash+`"`)
+	http.ServeContent(w, r, name, fi.ModTime(), rs)
+}
+
+// entry returns the entry of the file name, loading it if it is new or
+// has changed.
+func (s *FileServer) entry(name string, fi fs.FileInfo) (*fileEntry, error) {
+	s.mu.Lock()
+	e := s.cache[name]
+	if e == nil || !e.modTime.Equal(fi.ModTime()) || e.size != fi.Size() {
+		s.remove(name)
+		for len(s.cache) >= s.maxEntries {
+			s.evict()
+		}
+		e = &fileEntry{modTime: fi.ModTime(), size: fi.Size()}
+		s.cache[name] = e
+	}
+	s.mu.Unlock()
+
+	e.once.Do(func() { e.err = s.load(name, e) })
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e.err != nil {
+		// Try again on the next request.
+		if s.cache[name] == e {
+			s.remove(name)
+		}
+		return nil, e.err
+	}
+	if !e.loaded && s.cache[name] == e {
+		e.loaded = true
+		s.cacheBytes += len(e.gz)
+		for s.cacheBytes > s.maxBytes && len(s.cache) > 0 {
+			s.evict()
+		}
+	}
+	return e, nil
+}
+
+// remove drops the entry of the file name from the cache. It is called
+// with mu held.
+func (s *FileServer) remove(name string) {
+	if e := s.cache[name]; e != nil && e.loaded {
+		s.cacheBytes -= len(e.gz)
+	}
+	delete(s.cache, name)
+}
+
+// evict drops an arbitrary entry, the first in map iteration order. It is
+// called with mu held.
+func (s *FileServer) evict() {
+	for name := range s.cache {
+		s.remove(name)
+		return
+	}
+}
+
+// errNoGain stops writing a compressed variant that is not smaller than
+// its file.
+var errNoGain = errors.New("http: compressed variant is not smaller")
+
+// load hashes the file name and sniffs its type to fill in e, and
+// compresses it unless it has a .gz sibling or is not worth compressing.
+func (s *FileServer) load(name string, e *fileEntry) error {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var head [sniffLen]byte
+	n, err := io.ReadFull(f, head[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	e.ctype = contentType(name, head[:n])
+	h := sha256.New()
+	h.Write(head[:n])
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	e.hash = hex.EncodeToString(h.Sum(nil)[:16])
+	if e.size < int64(s.MinSize) || s.skip(e.ctype) {
+		return nil
+	}
+	if f, fi, err := s.openSibling(name); err == nil {
+		ok := s.checkSibling(e, f, fi)
+		f.Close()
+		if ok {
+			e.sibling = true
+			return nil
+		}
+		// A stale sibling is ignored, and the file compressed instead.
+	}
+
+	if s.CacheDir == "" {
+		var buf bytes.Buffer
+		if err := s.compress(&buf, name); err != nil {
+			return err
+		}
+		if int64(buf.Len()) < e.size {
+			e.gz = buf.Bytes()
+		}
+		return nil
+	}
+	p := filepath.Join(s.CacheDir, e.hash+".gz")
+	if _, err := os.Stat(p); err == nil {
+		e.gzPath = p
+		return nil
+	}
+	p, err = writeFileAtomic(s.CacheDir, e.hash+".gz", func(tmp *os.File) error {
+		if err := s.compress(tmp, name); err != nil {
+			return err
+		}
+		if fi, err := tmp.Stat(); err != nil || fi.Size() >= e.size {
+			return errNoGain
+		}
+		return nil
+	})
+	if err == errNoGain {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	e.gzPath = p
+	return nil
+}
+
+// compress writes the gzip compressed content of the file name to w,
+// reading it in a stream.
+func (s *FileServer) compress(w io.Writer, name string) error {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	z, _ := gzip.NewWriterLevel(w, s.level)
+	if _, err := io.Copy(z, f); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+// openVariant opens the compressed variant of the file name and returns
+// it with its ETag, or returns nil if it has gone.
+func (s *FileServer) openVariant(name string, e *fileEntry) (io.ReadSeekCloser, string) {
+	switch {
+	case e.gz != nil:
+		return nopCloser{bytes.NewReader(e.gz)}, e.hash + "-gzip"
+	case e.gzPath != "":
+		if f, err := os.Open(e.gzPath); err == nil {
+			return f, e.hash + "-gzip"
+		}
+	case e.sibling:
+		// The sibling can be rebuilt without its file changing, so it is
+		// checked again if it did, and its ETag depends on it too.
+		f, fi, err := s.openSibling(name)
+		if err != nil {
+			return nil, ""
+		}
+		if !s.checkSibling(e, f, fi) {
+			f.Close()
+			return nil, ""
+		}
+		return f, fmt.Sprintf("%s-gzip-%x-%x", e.hash, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return nil, ""
+}
+
+// openSibling opens the .gz sibling of the file name.
+func (s *FileServer) openSibling(name string) (io.ReadSeekCloser, fs.FileInfo, error) {
+	f, err := s.fsys.Open(name + ".gz")
+	if err != nil {
+		return nil, nil, err
+	}
+	fi, err := f.Stat()
+	rs, ok := f.(io.ReadSeekCloser)
+	if err == nil && (!ok || !fi.Mode().IsRegular()) {
+		err = fs.ErrInvalid
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return rs, fi, nil
+}
+
+// checkSibling reports whether the .gz sibling f, described by fi, holds
+// the content of the file of e. It decompresses f unless a sibling of the
+// same size and modification time was checked already, and leaves f at its
+// start.
+func (s *FileServer) checkSibling(e *fileEntry, f io.ReadSeeker, fi fs.FileInfo) bool {
+	s.mu.Lock()
+	sib := e.sib
+	s.mu.Unlock()
+	if sib.checked && sib.size == fi.Size() && sib.modTime.Equal(fi.ModTime()) {
+		return sib.ok
+	}
+	sib = sibState{checked: true, size: fi.Size(), modTime: fi.ModTime()}
+	if z, err := gzip.NewReader(f); err == nil {
+		h := sha256.New()
+		if _, err := io.Copy(h, z); err == nil {
+			sib.ok = hex.EncodeToString(h.Sum(nil)[:16]) == e.hash
+		}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		sib.ok = false
+	}
+	s.mu.Lock()
+	e.sib = sib
+	s.mu.Unlock()
+	return sib.ok
+}
+
+func (s *FileServer) skip(contentType string) bool {
+	if s.Skip != nil {
+		return s.Skip(contentType)
+	}
+	return DefaultSkip(contentType)
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
+
+// contentType returns the Content-Type of the file name starting with
+// data, from its extension or else its content.
+func contentType(name string, data []byte) string {
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		return ctype
+	}
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+	return http.DetectContentType(data)
+}
+
+// writeFileAtomic creates the file name in dir with the content that write
+// puts in a temporary file, creating dir if needed, so that the file never
+// appears partly written. It returns the path of the file. If write fails,
+// no file is created and its error is returned.
+func writeFileAtomic(dir, name string, write func(tmp *os.File) error) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, name+".tmp*")
+	if err != nil {
+		return "", err
+	}
+	err = write(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	p := filepath.Join(dir, name)
+	if err == nil {
+		err = os.Rename(tmp.Name(), p)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return p, nil
+}
+
+// Precompress writes a gzip compressed copy of each file of fsys into dir,
+// as the file name followed by ".gz", at the given compression level.
+// Files shorter than DefaultMinSize, of a type skipped by DefaultSkip, that
+// do not shrink, or whose name ends in ".gz" are left out, and so are the
+// copies that are newer than their file already.
+//
+// Precompress is meant to run at build time, for example from a go:generate
+// directive, so that a FileServer serving an embed.FS does not compress
+// anything at run time:
+//
+//	//go:generate go run ./cmd/precompress
+//	//go:embed static
+//	var static embed.FS
+//
+// where cmd/precompress calls
+//
+//	http.Precompress("static", os.DirFS("static"), gzip.BestCompression)
+//
+// The copies carry no name or modification time, so builds are
+// reproducible.
+func Precompress(dir string, fsys fs.FS, level int) error {
+	z, err := gzip.NewWriterLevel(io.Discard, level)
+	if err != nil {
+		return fmt.Errorf("http: invalid compression level: %d", level)
+	}
+	var buf bytes.Buffer
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() || strings.HasSuffix(name, ".gz") {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(name)+".gz")
+		if gi, err := os.Stat(dst); err == nil && !gi.ModTime().Before(fi.ModTime()) {
+			return nil
+		}
+		if fi.Size() < DefaultMinSize {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		if DefaultSkip(contentType(name, data)) {
+			return nil
+		}
+		buf.Reset()
+		z.Reset(&buf)
+		z.Write(data)
+		if err := z.Close(); err != nil {
+			return err
+		}
+		if buf.Len() >= len(data) {
+			return nil
+		}
+		_, err = writeFileAtomic(filepath.Dir(dst), filepath.Base(dst), func(tmp *os.File) error {
+			_, err := tmp.Write(buf.Bytes())
+			return err
+		})
+		return err
+	})
+}
@@ -0,0 +1,352 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/intel/fastgo/compress/gzip"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+	z.Write(data)
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// get serves a GET request for target and returns the response and its
+// decoded body.
+func get(t *testing.T, h http.Handler, target string, header ...string) (*http.Response, []byte) {
+	req := httptest.NewRequest("GET", target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	resp := rec.Result()
+	body := rec.Body.Bytes()
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		z, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("%s: %v", target, err)
+		}
+		if body, err = io.ReadAll(z); err != nil {
+			t.Fatalf("%s: %v", target, err)
+		}
+	}
+	return resp, body
+}
+
+func TestFileServer(t *testing.T) {
+	js := []byte(strings.Repeat("function f() { return 42; }\n", 1000))
+	lib := []byte(strings.Repeat("var lib = {};\n", 1000))
+	// A sibling that on-the-fly compression cannot have produced.
+	var mb bytes.Buffer
+	mz := gzip.NewWriter(&mb)
+	mz.Comment = "precompressed"
+	mz.Write(lib)
+	mz.Close()
+	marker := mb.Bytes()
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"app.js":         {Data: js, ModTime: mtime},
+		"lib.js":         {Data: lib, ModTime: mtime},
+		"lib.js.gz":      {Data: marker, ModTime: mtime},
+		"small.txt":      {Data: []byte("hello"), ModTime: mtime},
+		"photo.png":      {Data: bytes.Repeat([]byte{0x89}, 4096), ModTime: mtime},
+		"dir/index.html": {Data: []byte(strings.Repeat("<p>index</p>\n", 500)), ModTime: mtime},
+	}
+	if _, err := NewFileServer(fsys, 42); err == nil {
+		t.Fatal("NewFileServer accepted level 42")
+	}
+	s, err := NewFileServer(fsys, gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := get(t, s, "/app.js", "Accept-Encoding", "gzip")
+	if resp.Header.Get("Content-Encoding") != "gzip" || !bytes.Equal(body, js) {
+		t.Fatalf("app.js: Content-Encoding %q, %d bytes", resp.Header.Get("Content-Encoding"), len(body))
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.Contains(ct, "javascript") {
+		t.Fatalf("app.js: Content-Type %q", ct)
+	}
+	if resp.Header.Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("app.js: Vary %q", resp.Header.Get("Vary"))
+	}
+	gzETag := resp.Header.Get("ETag")
+	resp, body = get(t, s, "/app.js")
+	if resp.Header.Get("Content-Encoding") != "" || !bytes.Equal(body, js) {
+		t.Fatal("app.js: identity response is wrong")
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" || etag == gzETag {
+		t.Fatalf("ETags %s and %s", etag, gzETag)
+	}
+
+	// Each ETag only matches its own variant.
+	if resp, _ = get(t, s, "/app.js", "Accept-Encoding", "gzip", "If-None-Match", gzETag); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("gzip If-None-Match: status %d", resp.StatusCode)
+	}
+	if resp, _ = get(t, s, "/app.js", "If-None-Match", etag); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("identity If-None-Match: status %d", resp.StatusCode)
+	}
+	if resp, _ = get(t, s, "/app.js", "Accept-Encoding", "gzip", "If-None-Match", etag); resp.StatusCode != http.StatusOK {
+		t.Fatalf("mismatched If-None-Match: status %d", resp.StatusCode)
+	}
+
+	// A .gz sibling is served as it is.
+	req := httptest.NewRequest("GET", "/lib.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if !bytes.Equal(rec.Body.Bytes(), marker) {
+		t.Fatal("lib.js: .gz sibling not served")
+	}
+	if _, body = get(t, s, "/lib.js"); !bytes.Equal(body, lib) {
+		t.Fatal("lib.js: identity response is wrong")
+	}
+	// A rebuilt sibling gets a new ETag, although lib.js is the same.
+	siblingETag := rec.Header().Get("ETag")
+	fsys["lib.js.gz"] = &fstest.MapFile{Data: gzipBytes(t, lib), ModTime: mtime.Add(time.Minute)}
+	resp, body = get(t, s, "/lib.js", "Accept-Encoding", "gzip")
+	if !bytes.Equal(body, lib) || resp.Header.Get("ETag") == siblingETag {
+		t.Fatalf("lib.js: ETag %s kept after the sibling changed", siblingETag)
+	}
+	// A stale sibling is not served.
+	fsys["lib.js.gz"] = &fstest.MapFile{Data: gzipBytes(t, []byte("old lib")), ModTime: mtime.Add(2 * time.Minute)}
+	resp, body = get(t, s, "/lib.js", "Accept-Encoding", "gzip")
+	if !bytes.Equal(body, lib) || resp.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("lib.js: stale sibling served, Content-Encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+
+	for _, target := range []string{"/small.txt", "/photo.png"} {
+		resp, _ = get(t, s, target, "Accept-Encoding", "gzip")
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != "" {
+			t.Fatalf("%s: status %d, Content-Encoding %q", target, resp.StatusCode, resp.Header.Get("Content-Encoding"))
+		}
+	}
+	resp, body = get(t, s, "/dir/", "Accept-Encoding", "gzip")
+	if resp.Header.Get("Content-Encoding") != "gzip" || !bytes.Equal(body, fsys["dir/index.html"].Data) {
+		t.Fatal("dir/: index.html not compressed")
+	}
+	if resp, _ = get(t, s, "/dir"); resp.StatusCode != http.StatusMovedPermanently {
+		t.Fatalf("dir: status %d", resp.StatusCode)
+	}
+	if resp, _ = get(t, s, "/missing.js", "Accept-Encoding", "gzip"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("missing.js: status %d", resp.StatusCode)
+	}
+
+	// A changed file is compressed again.
+	js2 := []byte(strings.Repeat("function g() { return 43; }\n", 1000))
+	fsys["app.js"] = &fstest.MapFile{Data: js2, ModTime: mtime.Add(time.Hour)}
+	resp, body = get(t, s, "/app.js", "Accept-Encoding", "gzip")
+	if !bytes.Equal(body, js2) || resp.Header.Get("ETag") == gzETag {
+		t.Fatal("app.js: stale variant served after a change")
+	}
+}
+
+// countingFS counts the bytes read from the files of an fs.FS.
+type countingFS struct {
+	fstest.MapFS
+	n *int64
+}
+
+func (c countingFS) Open(name string) (fs.File, error) {
+	f, err := c.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return countingFile{f.(io.ReadSeeker), f, c.n}, nil
+}
+
+type countingFile struct {
+	io.ReadSeeker
+	fs.File
+	n *int64
+}
+
+func (f countingFile) Read(p []byte) (int, error) {
+	n, err := f.ReadSeeker.Read(p)
+	*f.n += int64(n)
+	return n, err
+}
+
+// TestFileServerSkipped checks that files that are not compressed are only
+// read to hash them.
+func TestFileServerSkipped(t *testing.T) {
+	var n int64
+	video := bytes.Repeat([]byte("not really a video"), 1<<16)
+	fsys := countingFS{fstest.MapFS{"clip.mp4": {Data: video, ModTime: time.Now()}}, &n}
+	s, err := NewFileServer(fsys, gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, body := get(t, s, "/clip.mp4", "Accept-Encoding", "gzip")
+	if resp.Header.Get("Content-Encoding") != "" || !bytes.Equal(body, video) {
+		t.Fatal("clip.mp4: wrong response")
+	}
+	if n != 2*int64(len(video)) {
+		t.Fatalf("read %d bytes of %d for hashing and serving", n, len(video))
+	}
+}
+
+// TestFileServerStaleSibling checks that a .gz sibling that does not match
+// its file is ignored, even with the same modification time.
+func TestFileServerStaleSibling(t *testing.T) {
+	js := []byte(strings.Repeat("console.log('new');\n", 1000))
+	old := []byte(strings.Repeat("console.log('old');\n", 1000))
+	fsys := fstest.MapFS{
+		"app.js":    {Data: js},
+		"app.js.gz": {Data: gzipBytes(t, old)},
+	}
+	s, err := NewFileServer(fsys, gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		resp, body := get(t, s, "/app.js", "Accept-Encoding", "gzip")
+		if resp.Header.Get("Content-Encoding") != "gzip" || !bytes.Equal(body, js) {
+			t.Fatalf("round %d: served %d bytes, Content-Encoding %q", i, len(body), resp.Header.Get("Content-Encoding"))
+		}
+	}
+}
+
+// TestFileServerEvict checks that the cache stays within its limits.
+func TestFileServerEvict(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := 0; i < 50; i++ {
+		fsys[fmt.Sprintf("f%d.js", i)] = &fstest.MapFile{Data: []byte(strings.Repeat(fmt.Sprintf("var v%d;\n", i), 500))}
+	}
+	s, err := NewFileServer(fsys, gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.maxEntries, s.maxBytes = 10, 500
+	for round := 0; round < 2; round++ {
+		for name, f := range fsys {
+			resp, body := get(t, s, "/"+name, "Accept-Encoding", "gzip")
+			if resp.Header.Get("Content-Encoding") != "gzip" || !bytes.Equal(body, f.Data) {
+				t.Fatalf("%s: wrong response", name)
+			}
+			if len(s.cache) > s.maxEntries || s.cacheBytes > s.maxBytes {
+				t.Fatalf("%s: cache holds %d entries and %d bytes", name, len(s.cache), s.cacheBytes)
+			}
+		}
+	}
+}
+
+func TestFileSystemServer(t *testing.T) {
+	dir := t.TempDir()
+	js := []byte(strings.Repeat("console.log('dir');\n", 1000))
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), js, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewFileSystemServer(http.Dir(dir), gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, body := get(t, s, "/app.js", "Accept-Encoding", "gzip")
+	if resp.Header.Get("Content-Encoding") != "gzip" || !bytes.Equal(body, js) {
+		t.Fatal("app.js: wrong response")
+	}
+	if resp, _ = get(t, s, "/missing.js"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("missing.js: status %d", resp.StatusCode)
+	}
+}
+
+func TestFileServerCacheDir(t *testing.T) {
+	js := []byte(strings.Repeat("console.log('cached');\n", 1000))
+	fsys := fstest.MapFS{"app.js": {Data: js, ModTime: time.Now()}}
+	dir := filepath.Join(t.TempDir(), "cache")
+	for i := 0; i < 2; i++ {
+		s, err := NewFileServer(fsys, gzip.DefaultCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.CacheDir = dir
+		resp, body := get(t, s, "/app.js", "Accept-Encoding", "gzip")
+		if resp.Header.Get("Content-Encoding") != "gzip" || !bytes.Equal(body, js) {
+			t.Fatalf("round %d: wrong response", i)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("round %d: cache holds %d files, %v", i, len(entries), err)
+		}
+	}
+}
+
+func TestPrecompress(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"app.js":         []byte(strings.Repeat("let x = 1;\n", 1000)),
+		"css/style.css":  []byte(strings.Repeat("body { margin: 0; }\n", 500)),
+		"small.txt":      []byte("hello"),
+		"img/photo.jpeg": bytes.Repeat([]byte{0xff}, 4096),
+	}
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Precompress(dir, os.DirFS(dir), -5); err == nil {
+		t.Fatal("Precompress accepted level -5")
+	}
+	if err := Precompress(dir, os.DirFS(dir), gzip.BestCompression); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		gz, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)+".gz"))
+		want := name == "app.js" || name == "css/style.css"
+		if want != (err == nil) {
+			t.Fatalf("%s.gz: %v", name, err)
+		}
+		if !want {
+			continue
+		}
+		z, err := gzip.NewReader(bytes.NewReader(gz))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := io.ReadAll(z); err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("%s.gz: wrong content, %v", name, err)
+		}
+	}
+
+	// A second run leaves the up-to-date copies alone.
+	p := filepath.Join(dir, "app.js.gz")
+	before, _ := os.Stat(p)
+	if err := Precompress(dir, os.DirFS(dir), gzip.BestCompression); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.Stat(p); !after.ModTime().Equal(before.ModTime()) {
+		t.Fatal("up-to-date copy was rewritten")
+	}
+
+	s, err := NewFileServer(os.DirFS(dir), gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, body := get(t, s, "/css/style.css", "Accept-Encoding", "gzip")
+	if resp.Header.Get("Content-Encoding") != "gzip" || !bytes.Equal(body, files["css/style.css"]) {
+		t.Fatal("style.css: precompressed copy not served")
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// acceptEncoding is the Accept-Encoding header sent by Transport.
+const acceptEncoding = "gzip, deflate"
+
+// Transport is an http.RoundTripper that asks for gzip or deflate compressed
+// responses and decompresses them with fastgo, and can compress request
+// bodies as well.
+//
+// Because Transport sets the Accept-Encoding header itself, the base
+// http.Transport does not add its own and leaves responses untouched, so
+// its built-in gzip decompression is never used. Requests that already
+// carry an Accept-Encoding header are sent as they are, and their
+// responses are returned undecoded. Like http.Transport, Transport does not
+// ask for compression on HEAD requests, whose responses have no body, or on
+// Range requests, where the returned range would be one of the compressed
+// representation.
+type Transport struct {
+	// Base sends the requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	dec         Decompressor // decoder pool, without limits
+	reqEncoding string       // coding of request bodies, or ""
+	reqComp     *Compressor
+}
+
+// NewTransport returns a Transport sending requests with base.
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{Base: base}
+}
+
+// CompressRequests makes t compress request bodies with the given content
+// coding, gzip or deflate, at the given level. Requests without a body or
+// with a Content-Encoding already set are not changed. An empty encoding
+// turns request compression off. The server must accept compressed
+// requests, for example through Decompressor.
+//
+// CompressRequests must not be called once t is in use.
+func (t *Transport) CompressRequests(encoding string, level int) error {
+	switch encoding {
+	case "":
+		t.reqEncoding, t.reqComp = "", nil
+		return nil
+	case encodingGzip, encodingDeflate:
+	default:
+		return fmt.Errorf("http: unsupported request encoding %q", encoding)
+	}
+	c, err := NewCompressor(level)
+	if err != nil {
+		return err
+	}
+	t.reqEncoding, t.reqComp = encoding, c
+	return nil
+}
+
+// CloseIdleConnections closes the idle connections of the base
+// RoundTripper, if it has a CloseIdleConnections method.
+func (t *Transport) CloseIdleConnections() {
+	type closeIdler interface {
+		CloseIdleConnections()
+	}
+	if tr, ok := t.base().(closeIdler); ok {
+		tr.CloseIdleConnections()
+	}
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	decode := req.Header.Get("Accept-Encoding") == "" &&
+		req.Header.Get("Range") == "" && req.Method != http.MethodHead
+	compress := t.reqEncoding != "" && req.Body != nil && req.Body != http.NoBody &&
+		req.Header.Get("Content-Encoding") == ""
+	if !decode && !compress {
+		return t.base().RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the request.
+	r2 := new(http.Request)
+	*r2 = *req
+	r2.Header = req.Header.Clone()
+	if decode {
+		r2.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	if compress {
+		r2.Header.Set("Content-Encoding", t.reqEncoding)
+		r2.Header.Del("Content-Length")
+		r2.ContentLength = -1
+		r2.Body = t.compressBody(req.Body)
+		if getBody := req.GetBody; getBody != nil {
+			r2.GetBody = func() (io.ReadCloser, error) {
+				b, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return t.compressBody(b), nil
+			}
+		}
+	}
+
+	resp, err := t.base().RoundTrip(r2)
+	if err != nil || !decode {
+		return resp, err
+	}
+	coding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch coding {
+	case encodingGzip, "x-gzip", encodingDeflate:
+	default:
+		return resp, nil
+	}
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	resp.Body = &responseBody{t: t, orig: resp.Body, coding: coding}
+	return resp, nil
+}
+
+// compressBody returns a reader of body compressed with t.reqEncoding.
+// The compression happens as the returned reader is read.
+func (t *Transport) compressBody(body io.ReadCloser) io.ReadCloser {
+	r := &requestBody{c: t.reqComp, encoding: t.reqEncoding, orig: body}
+	r.enc = t.reqComp.getEncoder(t.reqEncoding, &r.out)
+	return r
+}
+
+// requestBody is a compressed request body. The client may close it while
+// a Read is in progress, so its encoder goes back to the pool only once no
+// Read uses it.
+type requestBody struct {
+	c        *Compressor
+	encoding string
+	orig     io.ReadCloser
+
+	mu  sync.Mutex
+	enc encoder      // nil once closed
+	out bytes.Buffer // compressed data not read yet
+	buf []byte       // input read from orig
+	err error        // returned once out is drained
+}
+
+func (r *requestBody) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.enc == nil {
+		return 0, errClosedBody
+	}
+	for r.out.Len() == 0 && r.err == nil {
+		r.err = r.fill()
+	}
+	if r.out.Len() > 0 {
+		return r.out.Read(p)
+	}
+	return 0, r.err
+}
+
+// fill compresses the result of one Read of the original body, and closes
+// the encoder once the body has ended.
+func (r *requestBody) fill() error {
+	if r.buf == nil {
+		r.buf = make([]byte, 32*1024)
+	}
+	n, err := r.orig.Read(r.buf)
+	if _, werr := r.enc.Write(r.buf[:n]); werr != nil {
+		return werr
+	}
+	if err == io.EOF {
+		if cerr := r.enc.Close(); cerr != nil {
+			return cerr
+		}
+	}
+	return err
+}
+
+func (r *requestBody) Close() error {
+	// Closing the original body first unblocks a Read waiting for it.
+	err := r.orig.Close()
+	r.mu.Lock()
+	if r.enc != nil {
+		r.c.putEncoder(r.encoding, r.enc)
+		r.enc = nil
+	}
+	r.mu.Unlock()
+	return err
+}
+
+// responseBody decodes a response body on first Read, so that RoundTrip
+// does not wait for the body to arrive. Like requestBody, it returns its
+// decoder to the pool only once no Read uses it.
+type responseBody struct {
+	t      *Transport
+	orig   io.ReadCloser
+	coding string
+
+	mu  sync.Mutex
+	b   *body
+	err error
+}
+
+func (r *responseBody) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.b == nil {
+		r.b = r.t.dec.getBody()
+		if err := r.b.reset(r.orig, r.coding); err != nil {
+			// io.EOF means an empty body.
+			r.err = err
+			return 0, err
+		}
+	}
+	return r.b.Read(p)
+}
+
+func (r *responseBody) Close() error {
+	err := r.orig.Close()
+	r.mu.Lock()
+	if r.b != nil {
+		r.t.dec.putBody(r.b)
+		r.b = nil
+	}
+	r.err = errClosedBody
+	r.mu.Unlock()
+	return err
+}
+
+var errClosedBody = errors.New("http: read on closed body")
@@ -0,0 +1,283 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package http
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intel/fastgo/compress/gzip"
+)
+
+func TestTransport(t *testing.T) {
+	payload := strings.Repeat(`{"key": "value", "list": [1, 2, 3]}`+"\n", 2000)
+	var gotAccept string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAccept = r.Header.Get("Accept-Encoding")
+		r.Header.Set("Accept-Encoding", r.URL.Query().Get("enc"))
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, payload)
+		})).ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: NewTransport(nil)}
+
+	for _, enc := range []string{"gzip", "deflate", ""} {
+		resp, err := client.Get(srv.URL + "?enc=" + enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || string(body) != payload {
+			t.Fatalf("%q: read %d bytes, %v", enc, len(body), err)
+		}
+		if gotAccept != acceptEncoding {
+			t.Fatalf("server saw Accept-Encoding %q, want %q", gotAccept, acceptEncoding)
+		}
+		if resp.Uncompressed != (enc != "") || resp.Header.Get("Content-Encoding") != "" {
+			t.Fatalf("%q: Uncompressed = %v, Content-Encoding = %q", enc, resp.Uncompressed, resp.Header.Get("Content-Encoding"))
+		}
+	}
+
+	// A caller that asks for an encoding gets the raw response.
+	req, _ := http.NewRequest("GET", srv.URL+"?enc=gzip", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "gzip" || resp.Uncompressed {
+		t.Fatalf("explicit Accept-Encoding: response was decoded")
+	}
+}
+
+func TestTransportCompressRequests(t *testing.T) {
+	// Hex text compresses about 2:1, within the server's ratio limit.
+	raw := make([]byte, 50000)
+	rand.New(rand.NewSource(1)).Read(raw)
+	payload := []byte(hex.EncodeToString(raw))
+	var gotEncoding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding = r.Header.Get("Content-Encoding")
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+			return
+		}
+		DecompressHandler(echo).ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	tr := NewTransport(nil)
+	if err := tr.CompressRequests("br", 1); err == nil {
+		t.Fatal("CompressRequests(br) succeeded")
+	}
+	client := &http.Client{Transport: tr}
+	for _, enc := range []string{"gzip", "deflate"} {
+		if err := tr.CompressRequests(enc, 1); err != nil {
+			t.Fatal(err)
+		}
+		// The redirect makes the client resend the body through GetBody.
+		for _, path := range []string{"/", "/redirect"} {
+			resp, err := client.Post(srv.URL+path, "text/plain", bytes.NewReader(payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil || resp.StatusCode != http.StatusOK || !bytes.Equal(body, payload) {
+				t.Fatalf("%s %s: status %d, %d bytes, %v", enc, path, resp.StatusCode, len(body), err)
+			}
+			if gotEncoding != enc {
+				t.Fatalf("%s %s: server saw Content-Encoding %q", enc, path, gotEncoding)
+			}
+		}
+	}
+}
+
+func TestTransportRangeAndHead(t *testing.T) {
+	payload := strings.Repeat("0123456789", 1000)
+	var gotAccept string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAccept = r.Header.Get("Accept-Encoding")
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.ServeContent(w, r, "data.txt", time.Time{}, strings.NewReader(payload))
+		})).ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: NewTransport(nil)}
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	req.Header.Set("Range", "bytes=100-199")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusPartialContent || string(body) != payload[100:200] {
+		t.Fatalf("Range: status %d, body %q, %v", resp.StatusCode, body, err)
+	}
+	if gotAccept != "" || resp.Uncompressed {
+		t.Fatalf("Range: server saw Accept-Encoding %q, Uncompressed = %v", gotAccept, resp.Uncompressed)
+	}
+
+	resp, err = client.Head(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK || len(body) != 0 {
+		t.Fatalf("HEAD: status %d, %d bytes, %v", resp.StatusCode, len(body), err)
+	}
+	if gotAccept != "" || resp.Uncompressed {
+		t.Fatalf("HEAD: server saw Accept-Encoding %q, Uncompressed = %v", gotAccept, resp.Uncompressed)
+	}
+}
+
+type idleCloser struct {
+	http.RoundTripper
+	closed int
+}
+
+func (c *idleCloser) CloseIdleConnections() { c.closed++ }
+
+func TestTransportCloseIdleConnections(t *testing.T) {
+	base := &idleCloser{RoundTripper: http.DefaultTransport}
+	(&http.Client{Transport: NewTransport(base)}).CloseIdleConnections()
+	if base.closed != 1 {
+		t.Fatalf("base CloseIdleConnections called %d times, want 1", base.closed)
+	}
+	// A base without the method is left alone.
+	NewTransport(roundTripperFunc(nil)).CloseIdleConnections()
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+type closeCounter struct {
+	io.Reader
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestTransportCompressBody(t *testing.T) {
+	tr := NewTransport(nil)
+	if err := tr.CompressRequests("gzip", 42); err == nil {
+		t.Fatal("CompressRequests with level 42 succeeded")
+	}
+	payload := bytes.Repeat([]byte("request body "), 10000)
+	for _, enc := range []string{"gzip", "deflate"} {
+		if err := tr.CompressRequests(enc, 1); err != nil {
+			t.Fatal(err)
+		}
+		// The second round reuses the pooled compressor.
+		for i := 0; i < 2; i++ {
+			src := &closeCounter{Reader: bytes.NewReader(payload)}
+			rc := tr.compressBody(src)
+			compressed, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := rc.Close(); err != nil || src.closed != 1 {
+				t.Fatalf("%s: Close: %v, source closed %d times", enc, err, src.closed)
+			}
+			if _, err := rc.Read(make([]byte, 1)); err != errClosedBody {
+				t.Fatalf("%s: Read after Close: %v", enc, err)
+			}
+			b := tr.dec.getBody()
+			if err := b.reset(io.NopCloser(bytes.NewReader(compressed)), enc); err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(b)
+			if err != nil || !bytes.Equal(got, payload) {
+				t.Fatalf("%s: decoded %d bytes, %v", enc, len(got), err)
+			}
+		}
+	}
+}
+
+// TestTransportCloseDuringRead closes a response body while another
+// goroutine is blocked reading it, as a request canceled on timeout does.
+// Run with -race: the decoder must not go back to the pool while the Read
+// still uses it.
+func TestTransportCloseDuringRead(t *testing.T) {
+	// Incompressible, so that the first half of the data can be decoded
+	// long before the input stalls.
+	raw := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(raw)
+	payload := string(raw)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		io.WriteString(zw, payload)
+		if r.URL.Path == "/stall" {
+			zw.Flush()
+			w.(http.Flusher).Flush()
+			<-release
+			return
+		}
+		zw.Close()
+	}))
+	defer srv.Close()
+	defer close(release)
+	client := &http.Client{Transport: NewTransport(nil)}
+
+	for i := 0; i < 5; i++ {
+		resp, err := client.Get(srv.URL + "/stall")
+		if err != nil {
+			t.Fatal(err)
+		}
+		read := make(chan error)
+		go func() {
+			buf := make([]byte, len(payload)/2)
+			if _, err := io.ReadFull(resp.Body, buf); err != nil {
+				read <- err
+				return
+			}
+			read <- nil
+			for { // reads the rest, then blocks until Close
+				if _, err := resp.Body.Read(buf); err != nil {
+					read <- err
+					return
+				}
+			}
+		}()
+		if err := <-read; err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		resp.Body.Close()
+		if err := <-read; err == nil {
+			t.Fatal("Read after Close succeeded")
+		}
+
+		// The decoder of the closed body may be reused here.
+		resp, err = client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || string(body) != payload {
+			t.Fatalf("read %d bytes, %v", len(body), err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package png
+
+import (
+	"bytes"
+	"image"
+	stdpng "image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestDecodeStdlibTestdata decodes the PNG files of the standard library's
+// tests, including the PNG suite and invalid files, and checks that the
+// result matches image/png.
+func TestDecodeStdlibTestdata(t *testing.T) {
+	dir := filepath.Join(runtime.GOROOT(), "src", "image", "png", "testdata")
+	files, _ := filepath.Glob(filepath.Join(dir, "*.png"))
+	suite, _ := filepath.Glob(filepath.Join(dir, "pngsuite", "*.png"))
+	files = append(files, suite...)
+	if len(files) == 0 {
+		t.Skip("no image/png test data in GOROOT")
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := filepath.Base(file)
+		want, wantErr := stdpng.Decode(bytes.NewReader(data))
+		got, err := Decode(bytes.NewReader(data))
+		if (err == nil) != (wantErr == nil) {
+			t.Fatalf("%s: got error %v, want %v", name, err, wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if err := sameImage(got, want); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		wantCfg, _ := stdpng.DecodeConfig(bytes.NewReader(data))
+		cfg, err := DecodeConfig(bytes.NewReader(data))
+		if err != nil || cfg.Width != wantCfg.Width || cfg.Height != wantCfg.Height || cfg.ColorModel == nil {
+			t.Fatalf("%s: DecodeConfig = %+v, %v", name, cfg, err)
+		}
+	}
+}
+
+// TestRegistered checks that image.Decode recognizes PNG images.
+func TestRegistered(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testImages()["gray"]); err != nil {
+		t.Fatal(err)
+	}
+	if _, format, err := image.Decode(&buf); err != nil || format != "png" {
+		t.Fatalf("image.Decode = %q, %v", format, err)
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	var buf bytes.Buffer
+	m := testImages()["opaque"]
+	Encode(&buf, m)
+	b.SetBytes(int64(len(m.(*image.RGBA).Pix)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Decode(bytes.NewReader(buf.Bytes()))
+	}
+}
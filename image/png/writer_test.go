@@ -0,0 +1,237 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	stdpng "image/png"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// testImages returns images of every kind the encoder handles, with
+// smooth gradients and some noise, like photographs.
+func testImages() map[string]image.Image {
+	const w, h = 203, 97
+	rnd := rand.New(rand.NewSource(1))
+	r := image.Rect(3, -5, 3+w, -5+h)
+	gray := image.NewGray(r)
+	gray16 := image.NewGray16(r)
+	rgba := image.NewRGBA(r)
+	opaque := image.NewRGBA(r)
+	nrgba := image.NewNRGBA(r)
+	rgba64 := image.NewRGBA64(r)
+	nrgba64 := image.NewNRGBA64(r)
+	alpha := image.NewAlpha(r)
+	paletted := image.NewPaletted(r, palette.Plan9)
+	p16 := image.NewPaletted(r, palette.WebSafe[:16])
+	p4 := image.NewPaletted(r, palette.WebSafe[:4])
+	p2 := image.NewPaletted(r, color.Palette{color.Black, color.White})
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			v := uint8(x + 2*y + rnd.Intn(8))
+			a := uint8(x * 255 / r.Max.X)
+			gray.SetGray(x, y, color.Gray{v})
+			gray16.SetGray16(x, y, color.Gray16{uint16(v)<<8 | uint16(x)})
+			rgba.SetRGBA(x, y, color.RGBA{v / 2, v / 3, v / 4, 128 + v/2})
+			opaque.SetRGBA(x, y, color.RGBA{v, uint8(y * 2), uint8(x), 255})
+			nrgba.SetNRGBA(x, y, color.NRGBA{v, uint8(y), 3, a})
+			rgba64.SetRGBA64(x, y, color.RGBA64{uint16(v) << 7, 1000, uint16(x), 0xffff})
+			nrgba64.SetNRGBA64(x, y, color.NRGBA64{uint16(v) << 8, uint16(y), 7, uint16(a) << 8})
+			alpha.SetAlpha(x, y, color.Alpha{a})
+			paletted.SetColorIndex(x, y, v)
+			p16.SetColorIndex(x, y, v%16)
+			p4.SetColorIndex(x, y, v%4)
+			p2.SetColorIndex(x, y, v%2)
+		}
+	}
+	return map[string]image.Image{
+		"gray": gray, "gray16": gray16, "rgba": rgba, "opaque": opaque,
+		"nrgba": nrgba, "rgba64": rgba64, "nrgba64": nrgba64, "alpha": alpha,
+		"paletted": paletted, "p16": p16, "p4": p4, "p2": p2,
+		"sub": opaque.SubImage(image.Rect(10, 0, 50, 30)),
+	}
+}
+
+// sameImage reports how a and b differ, if they do.
+func sameImage(a, b image.Image) error {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return fmt.Errorf("size %v, want %v", a.Bounds().Size(), b.Bounds().Size())
+	}
+	if !reflect.DeepEqual(a.ColorModel(), b.ColorModel()) {
+		return fmt.Errorf("color model %T, want %T", a.ColorModel(), b.ColorModel())
+	}
+	da := a.Bounds().Min.Sub(b.Bounds().Min)
+	for y := b.Bounds().Min.Y; y < b.Bounds().Max.Y; y++ {
+		for x := b.Bounds().Min.X; x < b.Bounds().Max.X; x++ {
+			ca, cb := a.At(x+da.X, y+da.Y), b.At(x, y)
+			if ca != cb {
+				return fmt.Errorf("pixel (%d, %d) is %v, want %v", x, y, ca, cb)
+			}
+		}
+	}
+	return nil
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	levels := []CompressionLevel{DefaultCompression, NoCompression, BestSpeed, BestCompression, 2, 6}
+	for name, m := range testImages() {
+		var want bytes.Buffer
+		if err := stdpng.Encode(&want, m); err != nil {
+			t.Fatal(err)
+		}
+		wantImg, err := stdpng.Decode(bytes.NewReader(want.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, level := range levels {
+			var buf bytes.Buffer
+			enc := Encoder{CompressionLevel: level}
+			if err := enc.Encode(&buf, m); err != nil {
+				t.Fatalf("%s, level %d: %v", name, level, err)
+			}
+			got, err := stdpng.Decode(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("%s, level %d: image/png: %v", name, level, err)
+			}
+			if err := sameImage(got, wantImg); err != nil {
+				t.Fatalf("%s, level %d: image/png decoded %v", name, level, err)
+			}
+			if got, err = Decode(bytes.NewReader(buf.Bytes())); err != nil {
+				t.Fatalf("%s, level %d: %v", name, level, err)
+			}
+			if err := sameImage(got, wantImg); err != nil {
+				t.Fatalf("%s, level %d: %v", name, level, err)
+			}
+		}
+
+		// Images encoded by image/png decode the same.
+		got, err := Decode(bytes.NewReader(want.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := sameImage(got, wantImg); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+type pool struct{ b *EncoderBuffer }
+
+func (p *pool) Get() *EncoderBuffer  { return p.b }
+func (p *pool) Put(b *EncoderBuffer) { p.b = b }
+
+func TestEncoderBufferPool(t *testing.T) {
+	m := testImages()["opaque"]
+	enc := Encoder{CompressionLevel: BestSpeed, BufferPool: &pool{}}
+	var first, buf bytes.Buffer
+	if err := enc.Encode(&first, m); err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		buf.Reset()
+		enc.Encode(&buf, m)
+	})
+	if !bytes.Equal(buf.Bytes(), first.Bytes()) {
+		t.Fatal("encoding with a reused buffer differs")
+	}
+	if allocs > 5 {
+		t.Errorf("%v allocations per Encode with a buffer pool", allocs)
+	}
+}
+
+// rowFilters returns the filter types of the rows of a PNG image with rows
+// of stride bytes.
+func rowFilters(t *testing.T, data []byte, stride int) []byte {
+	var idat []byte
+	for data = data[8:]; len(data) >= 12; {
+		n := int(binary.BigEndian.Uint32(data))
+		if string(data[4:8]) == "IDAT" {
+			idat = append(idat, data[8:8+n]...)
+		}
+		data = data[12+n:]
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(idat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pix, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var filters []byte
+	for i := 0; i < len(pix); i += stride {
+		filters = append(filters, pix[i])
+	}
+	return filters
+}
+
+// TestEncodeFastFilter checks that the default encoder, whose level is
+// level 2, chooses row filters like level 2 does, among up and sub only.
+func TestEncodeFastFilter(t *testing.T) {
+	m := testImages()["opaque"]
+	stride := 1 + 3*m.Bounds().Dx()
+	filters := func(enc *Encoder) []byte {
+		var buf bytes.Buffer
+		var err error
+		if enc == nil {
+			err = Encode(&buf, m)
+		} else {
+			err = enc.Encode(&buf, m)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rowFilters(t, buf.Bytes(), stride)
+	}
+	want := filters(&Encoder{CompressionLevel: 2})
+	for _, f := range want {
+		if f != ftUp && f != ftSub {
+			t.Fatalf("level 2 used filter %d", f)
+		}
+	}
+	if got := filters(&Encoder{}); !bytes.Equal(got, want) {
+		t.Fatalf("default Encoder chose filters %v, want %v", got, want)
+	}
+	if got := filters(nil); !bytes.Equal(got, want) {
+		t.Fatalf("Encode chose filters %v, want %v", got, want)
+	}
+	if got := filters(&Encoder{CompressionLevel: 6}); bytes.Equal(got, want) {
+		t.Fatal("level 6 chose the filters of level 2")
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, image.NewRGBA(image.Rect(0, 0, 0, 10))); err == nil {
+		t.Fatal("encoded an empty image")
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	m := testImages()["opaque"]
+	for _, level := range []CompressionLevel{BestSpeed, 2, DefaultCompression} {
+		b.Run(fmt.Sprint("level", level), func(b *testing.B) {
+			enc := Encoder{CompressionLevel: level, BufferPool: &pool{}}
+			var buf bytes.Buffer
+			enc.Encode(&buf, m)
+			b.SetBytes(int64(len(m.(*image.RGBA).Pix)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				enc.Encode(&buf, m)
+			}
+			b.ReportMetric(float64(buf.Len()), "bytes/op")
+		})
+	}
+}
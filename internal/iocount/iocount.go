@@ -0,0 +1,21 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package iocount provides a reader that counts the bytes read through it,
+// which the decoders use to report offsets in their compressed input.
+package iocount
+
+import "io"
+
+// Reader counts the bytes read from R.
+type Reader struct {
+	R io.Reader
+	N int64 // bytes read so far
+}
+
+// Read reads from R and adds the number of bytes read to N.
+func (c *Reader) Read(p []byte) (int, error) {
+	n, err := c.R.Read(p)
+	c.N += int64(n)
+	return n, err
+}
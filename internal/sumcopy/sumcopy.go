@@ -0,0 +1,35 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package sumcopy copies data and feeds it to a checksum, which the
+// compressors and decompressors use to maintain the CRC-32 and Adler-32 of
+// gzip and zlib streams.
+package sumcopy
+
+import "hash"
+
+// chunkSize is the size of the pieces in which data is copied and
+// checksummed. It is small enough for a piece to still be in the L1 cache
+// when the checksum reads it back.
+const chunkSize = 4 * 1024
+
+// Copy copies src into dst like copy and feeds the copied bytes to sum, if
+// it is not nil. The copy and the checksum are two passes, but they take
+// turns over one cache-sized piece at a time, so the data only comes from
+// memory once.
+func Copy(dst, src []byte, sum hash.Hash32) int {
+	if sum == nil {
+		return copy(dst, src)
+	}
+	n := 0
+	for n < len(dst) && n < len(src) {
+		end := n + chunkSize
+		if end > len(src) {
+			end = len(src)
+		}
+		m := copy(dst[n:], src[n:end])
+		sum.Write(dst[n : n+m])
+		n += m
+	}
+	return n
+}
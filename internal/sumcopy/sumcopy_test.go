@@ -0,0 +1,57 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package sumcopy
+
+import (
+	"bytes"
+	"hash"
+	"hash/adler32"
+	"hash/crc32"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	src := bytes.Repeat([]byte("checksummed copy "), 1000)
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, 3*chunkSize + 5, len(src), len(src) + 10} {
+		dst := make([]byte, size)
+		sum := crc32.NewIEEE()
+		n := Copy(dst, src, sum)
+		want := copy(make([]byte, size), src)
+		if n != want || !bytes.Equal(dst[:n], src[:n]) || sum.Sum32() != crc32.ChecksumIEEE(src[:n]) {
+			t.Fatalf("size %d: Copy = %d, want %d", size, n, want)
+		}
+		if n := Copy(dst, src, nil); n != want {
+			t.Fatalf("size %d: Copy without sum = %d, want %d", size, n, want)
+		}
+	}
+}
+
+// BenchmarkCopy compares Copy with a copy followed by a checksum of the
+// whole buffer, on buffers larger than the L1 and L2 caches.
+func BenchmarkCopy(b *testing.B) {
+	src := make([]byte, 4<<20)
+	dst := make([]byte, len(src))
+	for _, h := range []struct {
+		name string
+		new  func() hash.Hash32
+	}{
+		{"crc32", func() hash.Hash32 { return crc32.NewIEEE() }},
+		{"adler32", func() hash.Hash32 { return adler32.New() }},
+	} {
+		sum := h.new()
+		b.Run(h.name+"/chunked", func(b *testing.B) {
+			b.SetBytes(int64(len(src)))
+			for i := 0; i < b.N; i++ {
+				Copy(dst, src, sum)
+			}
+		})
+		b.Run(h.name+"/twopass", func(b *testing.B) {
+			b.SetBytes(int64(len(src)))
+			for i := 0; i < b.N; i++ {
+				copy(dst, src)
+				sum.Write(dst)
+			}
+		})
+	}
+}
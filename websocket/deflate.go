@@ -0,0 +1,400 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+// Package websocket implements the permessage-deflate WebSocket extension of
+// RFC 7692 with the Intel-optimized fastgo DEFLATE compressor and inflater.
+//
+// It is a message-level codec for WebSocket libraries: it compresses the
+// payload of a message before it is framed, and decompresses the payload
+// of a message received with the RSV1 bit set once it is reassembled.
+// Framing and the negotiation of the extension are left to the library;
+// Params helps with the latter.
+//
+// Compressing and decompressing a message appends to a caller's buffer and
+// allocates nothing once the buffers have grown to the size of the messages.
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/intel/fastgo/compress/flate"
+)
+
+// Window sizes, as base-two logarithms, allowed by RFC 7692.
+const (
+	MinWindowBits = 8
+	MaxWindowBits = 15
+)
+
+// ExtensionName is the extension token of permessage-deflate.
+const ExtensionName = "permessage-deflate"
+
+// ErrMessageTooLarge is returned by Decompress when a message decompresses
+// to more than the Decompressor's MaxSize.
+var ErrMessageTooLarge = errors.New("websocket: decompressed message too large")
+
+// syncTail is the end of the empty stored block of a sync flush, which
+// senders remove from each message and receivers put back.
+var syncTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// decodeTail is appended to a received payload. It restores the sync flush
+// tail, then adds a final empty stored block so that the inflater sees a
+// complete stream.
+var decodeTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+// Params are the parameters of a permessage-deflate extension offer or
+// response, from the point of view of the server and the client.
+type Params struct {
+	// ServerNoContextTakeover makes the server reset its compressor
+	// between messages.
+	ServerNoContextTakeover bool
+	// ClientNoContextTakeover makes the client reset its compressor
+	// between messages.
+	ClientNoContextTakeover bool
+	// ServerMaxWindowBits limits the LZ77 window of the server's
+	// compressor to 2^ServerMaxWindowBits bytes. Zero means 15.
+	ServerMaxWindowBits int
+	// ClientMaxWindowBits limits the LZ77 window of the client's
+	// compressor to 2^ClientMaxWindowBits bytes. Zero means 15. In an
+	// offer, the parameter without a value is parsed as 15.
+	ClientMaxWindowBits int
+}
+
+// ParseParams parses a single permessage-deflate extension from a
+// Sec-WebSocket-Extensions header, such as
+// "permessage-deflate; client_max_window_bits".
+func ParseParams(ext string) (Params, error) {
+	var p Params
+	parts := strings.Split(ext, ";")
+	if name := strings.TrimSpace(parts[0]); !strings.EqualFold(name, ExtensionName) {
+		return p, fmt.Errorf("websocket: not a %s extension: %q", ExtensionName, name)
+	}
+	seen := make(map[string]bool, len(parts))
+	for _, param := range parts[1:] {
+		name, value := strings.TrimSpace(param), ""
+		if i := strings.IndexByte(name, '='); i >= 0 {
+			name, value = strings.TrimSpace(name[:i]), strings.Trim(strings.TrimSpace(name[i+1:]), `"`)
+		}
+		name = strings.ToLower(name)
+		if seen[name] {
+			return p, fmt.Errorf("websocket: duplicate %s parameter", name)
+		}
+		seen[name] = true
+		var err error
+		switch name {
+		case "server_no_context_takeover":
+			p.ServerNoContextTakeover = true
+		case "client_no_context_takeover":
+			p.ClientNoContextTakeover = true
+		case "server_max_window_bits":
+			p.ServerMaxWindowBits, err = parseWindowBits(name, value, false)
+		case "client_max_window_bits":
+			p.ClientMaxWindowBits, err = parseWindowBits(name, value, true)
+		default:
+			err = fmt.Errorf("websocket: unknown %s parameter %q", ExtensionName, name)
+		}
+		if err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+func parseWindowBits(name, value string, optional bool) (int, error) {
+	if value == "" && optional {
+		return MaxWindowBits, nil
+	}
+	bits, err := strconv.Atoi(value)
+	if err != nil || bits < MinWindowBits || bits > MaxWindowBits {
+		return 0, fmt.Errorf("websocket: invalid %s value %q", name, value)
+	}
+	return bits, nil
+}
+
+// String formats p as a Sec-WebSocket-Extensions element. Window sizes of
+// zero are left out.
+func (p Params) String() string {
+	s := ExtensionName
+	if p.ServerNoContextTakeover {
+		s += "; server_no_context_takeover"
+	}
+	if p.ClientNoContextTakeover {
+		s += "; client_no_context_takeover"
+	}
+	if p.ServerMaxWindowBits != 0 {
+		s += "; server_max_window_bits=" + strconv.Itoa(p.ServerMaxWindowBits)
+	}
+	if p.ClientMaxWindowBits != 0 {
+		s += "; client_max_window_bits=" + strconv.Itoa(p.ClientMaxWindowBits)
+	}
+	return s
+}
+
+// checkWindowBits returns bits, with zero meaning the largest window.
+func checkWindowBits(bits int) (int, error) {
+	if bits == 0 {
+		return MaxWindowBits, nil
+	}
+	if bits < MinWindowBits || bits > MaxWindowBits {
+		return 0, fmt.Errorf("websocket: invalid window size: %d bits", bits)
+	}
+	return bits, nil
+}
+
+// A Codec compresses the messages sent on one end of a connection and
+// decompresses those received from the other end.
+type Codec struct {
+	*Compressor
+	*Decompressor
+}
+
+// NewCodec returns the Codec of the server, if server is set, or of the
+// client of a connection that agreed on p. Outgoing messages are
+// compressed at the given level.
+func NewCodec(p Params, server bool, level int) (*Codec, error) {
+	ownBits, ownTakeover := p.ClientMaxWindowBits, !p.ClientNoContextTakeover
+	peerBits, peerTakeover := p.ServerMaxWindowBits, !p.ServerNoContextTakeover
+	if server {
+		ownBits, peerBits = peerBits, ownBits
+		ownTakeover, peerTakeover = peerTakeover, ownTakeover
+	}
+	c, err := NewCompressor(level, ownBits, ownTakeover)
+	if err != nil {
+		return nil, err
+	}
+	d, err := NewDecompressor(peerBits, peerTakeover)
+	if err != nil {
+		return nil, err
+	}
+	return &Codec{c, d}, nil
+}
+
+// appendWriter appends what is written to b.
+type appendWriter struct {
+	b []byte
+}
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	w.b = append(w.b, p...)
+	return len(p), nil
+}
+
+// A Compressor compresses the messages sent on a connection.
+//
+// With context takeover, the LZ77 window is kept from one message to the
+// next and the Compressor holds its own deflate Writer. Without, writers
+// are reset between messages and shared through a pool.
+//
+// A Compressor must not be used by several goroutines at once.
+type Compressor struct {
+	pool *sync.Pool    // shared writers, without context takeover
+	fw   *flate.Writer // own writer, with context takeover
+	out  appendWriter
+}
+
+// writerKey identifies a pool of interchangeable writers.
+type writerKey struct {
+	level int
+	small bool // 4KB window
+}
+
+var writerPools sync.Map // writerKey to *sync.Pool
+
+// NewCompressor returns a Compressor compressing at the given level with a
+// window of 2^windowBits bytes, zero meaning 2^15, and with or without
+// context takeover.
+//
+// The window size is mapped onto the windows of the fastgo compressor:
+// 15 bits use the regular 32KB window, 12 to 14 bits the 4KB one, and
+// below that, where no window is available, the messages are compressed
+// with Huffman coding only.
+func NewCompressor(level, windowBits int, contextTakeover bool) (*Compressor, error) {
+	bits, err := checkWindowBits(windowBits)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := flate.NewWriter(io.Discard, level); err != nil {
+		return nil, fmt.Errorf("websocket: invalid compression level: %d", level)
+	}
+	key := writerKey{level: level}
+	switch {
+	case bits < 12 && level != flate.NoCompression:
+		key.level = flate.HuffmanOnly
+	case bits < MaxWindowBits:
+		key.small = true
+	}
+	c := &Compressor{}
+	if contextTakeover {
+		c.fw = newWriter(key, &c.out)
+	} else {
+		pool, _ := writerPools.LoadOrStore(key, &sync.Pool{New: func() interface{} {
+			return newWriter(key, io.Discard)
+		}})
+		c.pool = pool.(*sync.Pool)
+	}
+	return c, nil
+}
+
+func newWriter(key writerKey, w io.Writer) *flate.Writer {
+	// The level was checked by NewCompressor.
+	if key.small {
+		fw, _ := flate.NewWriterwWith4KWindow(w, key.level)
+		return fw
+	}
+	fw, _ := flate.NewWriter(w, key.level)
+	return fw
+}
+
+// Compress appends the compressed payload of msg to dst and returns the
+// extended buffer. The payload ends with a sync flush, whose trailing
+// 00 00 ff ff is removed as RFC 7692 requires. An empty message gives
+// the single byte 0x00.
+func (c *Compressor) Compress(dst, msg []byte) ([]byte, error) {
+	if len(msg) == 0 {
+		// The header of an empty stored block. Each message ends on a
+		// byte boundary, so it can go in between without the writer.
+		return append(dst, 0x00), nil
+	}
+	c.out.b = dst
+	fw := c.fw
+	if fw == nil {
+		fw = c.pool.Get().(*flate.Writer)
+		fw.Reset(&c.out)
+	}
+	_, err := fw.Write(msg)
+	if err == nil {
+		err = fw.Flush()
+	}
+	if c.fw == nil {
+		fw.Reset(io.Discard) // drop the reference to c.out
+		c.pool.Put(fw)
+	}
+	out := c.out.b
+	c.out.b = nil
+	if err != nil {
+		return dst, err
+	}
+	if n := len(out) - len(syncTail); n >= len(dst) && string(out[n:]) == string(syncTail) {
+		out = out[:n]
+	}
+	return out, nil
+}
+
+// A Decompressor decompresses the messages received on a connection.
+//
+// With context takeover, the Decompressor keeps the last 2^windowBits
+// bytes of output, which the next message may refer to. Inflaters are
+// shared through a pool in any case.
+//
+// A Decompressor must not be used by several goroutines at once.
+type Decompressor struct {
+	// MaxSize is the largest decompressed message accepted, in bytes.
+	// Zero means no limit.
+	MaxSize int
+
+	takeover bool
+	window   int    // size of the window kept with context takeover
+	hist     []byte // output history, its last window bytes are used
+	src      payloadReader
+}
+
+var readerPool sync.Pool
+
+// NewDecompressor returns a Decompressor of messages compressed with a
+// window of 2^windowBits bytes, zero meaning 2^15, and with or without
+// context takeover.
+func NewDecompressor(windowBits int, contextTakeover bool) (*Decompressor, error) {
+	bits, err := checkWindowBits(windowBits)
+	if err != nil {
+		return nil, err
+	}
+	return &Decompressor{takeover: contextTakeover, window: 1 << bits}, nil
+}
+
+// Decompress appends the decompressed content of payload, a message
+// payload as produced by Compress, to dst and returns the extended buffer.
+func (d *Decompressor) Decompress(dst, payload []byte) ([]byte, error) {
+	if len(payload) == 0 {
+		return dst, nil
+	}
+	d.src = payloadReader{payload: payload}
+	var dict []byte
+	if d.takeover {
+		dict = d.hist
+	}
+	fr, ok := readerPool.Get().(io.ReadCloser)
+	if ok {
+		fr.(flate.Resetter).Reset(&d.src, dict)
+	} else {
+		fr = flate.NewReaderDict(&d.src, dict)
+	}
+	start := len(dst)
+	var err error
+	for {
+		if len(dst) == cap(dst) {
+			dst = append(dst, 0)[:len(dst)]
+		}
+		var n int
+		n, err = fr.Read(dst[len(dst):cap(dst)])
+		dst = dst[:len(dst)+n]
+		if d.MaxSize > 0 && len(dst)-start > d.MaxSize {
+			dst, err = dst[:start+d.MaxSize], ErrMessageTooLarge
+		}
+		if err != nil {
+			break
+		}
+	}
+	fr.(flate.Resetter).Reset(nil, nil) // drop the reference to d.src
+	readerPool.Put(fr)
+	d.src = payloadReader{}
+	if err != io.EOF {
+		if err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("websocket: truncated compressed message: %w", err)
+		}
+		d.hist = d.hist[:0] // the next message cannot be decoded
+		return dst, err
+	}
+	if d.takeover {
+		d.remember(dst[start:])
+	}
+	return dst, nil
+}
+
+// remember adds msg to the output history, keeping its last d.window
+// bytes.
+func (d *Decompressor) remember(msg []byte) {
+	if len(msg) >= d.window {
+		d.hist = append(d.hist[:0], msg[len(msg)-d.window:]...)
+		return
+	}
+	if keep := d.window - len(msg); len(d.hist) > keep {
+		n := copy(d.hist, d.hist[len(d.hist)-keep:])
+		d.hist = d.hist[:n]
+	}
+	d.hist = append(d.hist, msg...)
+}
+
+// payloadReader reads a message payload followed by decodeTail.
+type payloadReader struct {
+	payload []byte
+	tail    int // bytes of decodeTail read
+}
+
+func (r *payloadReader) Read(p []byte) (int, error) {
+	n := copy(p, r.payload)
+	r.payload = r.payload[n:]
+	if n < len(p) && r.tail < len(decodeTail) {
+		m := copy(p[n:], decodeTail[r.tail:])
+		r.tail += m
+		n += m
+	}
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
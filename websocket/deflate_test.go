@@ -0,0 +1,188 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestParams(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Params
+		out  string
+	}{
+		{"permessage-deflate", Params{}, "permessage-deflate"},
+		{"permessage-deflate; client_max_window_bits", Params{ClientMaxWindowBits: 15},
+			"permessage-deflate; client_max_window_bits=15"},
+		{`Permessage-Deflate ; server_no_context_takeover; client_no_context_takeover; server_max_window_bits="10"; client_max_window_bits=12`,
+			Params{true, true, 10, 12},
+			"permessage-deflate; server_no_context_takeover; client_no_context_takeover; server_max_window_bits=10; client_max_window_bits=12"},
+	} {
+		p, err := ParseParams(tc.in)
+		if err != nil || p != tc.want {
+			t.Fatalf("ParseParams(%q) = %+v, %v; want %+v", tc.in, p, err, tc.want)
+		}
+		if s := p.String(); s != tc.out {
+			t.Fatalf("String() = %q, want %q", s, tc.out)
+		}
+	}
+	for _, in := range []string{
+		"x-webkit-deflate-frame",
+		"permessage-deflate; server_max_window_bits",
+		"permessage-deflate; server_max_window_bits=7",
+		"permessage-deflate; client_max_window_bits=16",
+		"permessage-deflate; server_no_context_takeover; server_no_context_takeover",
+		"permessage-deflate; mystery",
+	} {
+		if _, err := ParseParams(in); err == nil {
+			t.Fatalf("ParseParams(%q) succeeded", in)
+		}
+	}
+}
+
+// testMessages returns messages of varying sizes that share content, like
+// the JSON events of a realtime feed, plus an empty and a random one.
+func testMessages(n int) [][]byte {
+	rnd := rand.New(rand.NewSource(1))
+	msgs := [][]byte{{}}
+	for i := 0; i < n; i++ {
+		var b bytes.Buffer
+		for j := rnd.Intn(40); j >= 0; j-- {
+			fmt.Fprintf(&b, `{"channel":"ticker","symbol":"SYM%d","price":%d.%02d},`, rnd.Intn(20), rnd.Intn(1000), rnd.Intn(100))
+		}
+		msgs = append(msgs, b.Bytes())
+	}
+	random := make([]byte, 70000)
+	rnd.Read(random)
+	return append(msgs, random, []byte{})
+}
+
+func TestRoundTrip(t *testing.T) {
+	msgs := testMessages(200)
+	for _, level := range []int{flate.NoCompression, flate.HuffmanOnly, 1, 2, 6, 9} {
+		for _, bits := range []int{0, 14, 12, 9} {
+			for _, takeover := range []bool{true, false} {
+				p := Params{
+					ServerMaxWindowBits:     bits,
+					ClientMaxWindowBits:     bits,
+					ServerNoContextTakeover: !takeover,
+					ClientNoContextTakeover: !takeover,
+				}
+				server, err := NewCodec(p, true, level)
+				if err != nil {
+					t.Fatal(err)
+				}
+				client, err := NewCodec(p, false, level)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var payload, got []byte
+				for i, msg := range msgs {
+					from, to := server, client
+					if i%3 == 0 {
+						from, to = client, server
+					}
+					if payload, err = from.Compress(payload[:0], msg); err != nil {
+						t.Fatal(err)
+					}
+					if bytes.HasSuffix(payload, syncTail) {
+						t.Fatalf("%+v level %d: payload ends with the sync flush tail", p, level)
+					}
+					if len(msg) == 0 && !bytes.Equal(payload, []byte{0}) {
+						t.Fatalf("%+v level %d: empty message compressed to %x", p, level, payload)
+					}
+					if got, err = to.Decompress(got[:0], payload); err != nil || !bytes.Equal(got, msg) {
+						t.Fatalf("%+v level %d: message %d: got %d bytes, want %d, %v", p, level, i, len(got), len(msg), err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestStdlibInterop checks the codec against the standard library, which
+// sees the messages of a connection with context takeover as one stream.
+func TestStdlibInterop(t *testing.T) {
+	msgs := testMessages(100)
+
+	c, err := NewCompressor(1, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stream, want []byte
+	for _, msg := range msgs {
+		stream, _ = c.Compress(stream, msg)
+		stream = append(stream, syncTail...)
+		want = append(want, msg...)
+	}
+	got, err := io.ReadAll(flate.NewReader(bytes.NewReader(stream)))
+	if err != io.ErrUnexpectedEOF || !bytes.Equal(got, want) {
+		t.Fatalf("stdlib decoded %d bytes, want %d, %v", len(got), len(want), err)
+	}
+
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.BestCompression)
+	d, err := NewDecompressor(0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, msg := range msgs {
+		buf.Reset()
+		fw.Write(msg)
+		fw.Flush()
+		payload := bytes.TrimSuffix(buf.Bytes(), syncTail)
+		if got, err = d.Decompress(got[:0], payload); err != nil || !bytes.Equal(got, msg) {
+			t.Fatalf("message %d: got %d bytes, want %d, %v", i, len(got), len(msg), err)
+		}
+	}
+}
+
+func TestDecompressErrors(t *testing.T) {
+	c, _ := NewCompressor(1, 0, false)
+	payload, _ := c.Compress(nil, bytes.Repeat([]byte("a"), 100000))
+	d, _ := NewDecompressor(0, false)
+	d.MaxSize = 1000
+	if got, err := d.Decompress(nil, payload); err != ErrMessageTooLarge || len(got) != 1000 {
+		t.Fatalf("got %d bytes, %v; want 1000, %v", len(got), err, ErrMessageTooLarge)
+	}
+	d.MaxSize = 0
+	if _, err := d.Decompress(nil, payload[:len(payload)/2]); err == nil {
+		t.Fatal("truncated payload decoded")
+	}
+	if _, err := d.Decompress(nil, []byte{0xff, 0xff, 0xff}); err == nil {
+		t.Fatal("corrupt payload decoded")
+	}
+	if _, err := NewCompressor(42, 0, true); err == nil {
+		t.Fatal("NewCompressor accepted level 42")
+	}
+	if _, err := NewDecompressor(16, true); err == nil {
+		t.Fatal("NewDecompressor accepted 16 window bits")
+	}
+}
+
+func TestAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool does not keep items under the race detector")
+	}
+	msg := testMessages(1)[1]
+	for _, takeover := range []bool{true, false} {
+		c, _ := NewCompressor(1, 0, takeover)
+		d, _ := NewDecompressor(0, takeover)
+		payload, _ := c.Compress(nil, msg)
+		out, _ := d.Decompress(nil, payload)
+		allocs := testing.AllocsPerRun(100, func() {
+			payload, _ = c.Compress(payload[:0], msg)
+			out, _ = d.Decompress(out[:0], payload)
+		})
+		if allocs > 0 {
+			t.Errorf("context takeover %v: %v allocations per message", takeover, allocs)
+		}
+	}
+}
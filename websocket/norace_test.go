@@ -0,0 +1,9 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build !race
+// +build !race
+
+package websocket
+
+const raceEnabled = false
@@ -0,0 +1,11 @@
+// Copyright (c) 2024, Intel Corporation.
+// SPDX-License-Identifier: BSD-3-Clause
+
+//go:build race
+// +build race
+
+package websocket
+
+// raceEnabled is set when the race detector, which makes sync.Pool drop
+// items at random, is on.
+const raceEnabled = true